@@ -0,0 +1,284 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+// Package aggregate rolls raw insight samples into summaries over fixed windows
+package aggregate
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+const (
+	DefaultWindow     = time.Minute
+	DefaultMaxSamples = 1024
+
+	// unit of the count summary, other summaries keep the unit of the samples
+	CountUnit = "Count"
+)
+
+var DefaultPercentiles = []float64{50, 90, 99}
+
+// Aggregator groups samples by appliance, name, canonical unit and dimensions into
+// windows aligned to Window and emits one insight per statistic, named like
+// <name>.count, <name>.sum, <name>.min, <name>.max, <name>.last and
+// <name>.p90. It is safe for concurrent use, the zero value aggregates over
+// DefaultWindow without percentiles.
+type Aggregator struct {
+	Window time.Duration
+
+	// percentiles to emit, approximated from at most MaxSamples samples per window
+	Percentiles []float64
+	MaxSamples  int
+
+	mu     sync.Mutex
+	series map[seriesKey]*series
+	random *rand.Rand
+}
+
+type seriesKey struct {
+	applianceId string
+	name        string
+	unit        string
+	dimensions  string
+	start       int64
+}
+
+type series struct {
+	template *appliance.Insight
+	start    time.Time
+	count    int
+	sum      float64
+	min      float64
+	max      float64
+	last     float64
+	lastAt   time.Time
+	samples  []float64
+}
+
+func NewAggregator(window time.Duration) *Aggregator {
+	return &Aggregator{
+		Window:      window,
+		Percentiles: DefaultPercentiles,
+		MaxSamples:  DefaultMaxSamples,
+	}
+}
+
+// Add records insights, the ones without metric are ignored and a missing
+// timestamp is taken as now
+func (A *Aggregator) Add(insights ...*appliance.Insight) {
+	A.mu.Lock()
+	defer A.mu.Unlock()
+	if A.series == nil {
+		A.series = map[seriesKey]*series{}
+	}
+	for _, insight := range insights {
+		if insight == nil || insight.Metric == nil {
+			continue
+		}
+		at := time.Time(insight.Timestamp)
+		if at.IsZero() {
+			at = time.Now()
+		}
+		start := at.Truncate(A.window())
+		unit := insight.Metric.Unit
+		if canonical, err := appliance.CanonicalUnit(unit); err == nil {
+			unit = canonical
+		}
+		key := seriesKey{
+			applianceId: insight.ApplianceId,
+			name:        insight.Name,
+			unit:        unit,
+			dimensions:  canonicalKey(insight.Dimensions),
+			start:       start.UnixNano(),
+		}
+		s, ok := A.series[key]
+		if !ok {
+			// callers may reuse the insight, keep what the summaries need
+			template := *insight
+			template.Metric = &appliance.Metric{Unit: unit}
+			template.Dimensions = canonicalDimensions(insight.Dimensions)
+			s = &series{template: &template, start: start}
+			A.series[key] = s
+		}
+		A.record(s, insight.Metric.Value, at)
+	}
+}
+
+func (A *Aggregator) record(s *series, value float64, at time.Time) {
+	if s.count == 0 || value < s.min {
+		s.min = value
+	}
+	if s.count == 0 || value > s.max {
+		s.max = value
+	}
+	if !at.Before(s.lastAt) {
+		s.last = value
+		s.lastAt = at
+	}
+	s.count++
+	s.sum += value
+
+	// reservoir sampling keeps a uniform sample of the window for percentiles
+	if len(s.samples) < A.maxSamples() {
+		s.samples = append(s.samples, value)
+	} else if i := A.rand().Intn(s.count); i < len(s.samples) {
+		s.samples[i] = value
+	}
+}
+
+// rand returns the source of the reservoir sampling, it must be called with
+// mu held
+func (A *Aggregator) rand() *rand.Rand {
+	if A.random == nil {
+		A.random = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return A.random
+}
+
+// Flush emits the summaries of windows which ended at or before now and
+// forgets them
+func (A *Aggregator) Flush(now time.Time) []*appliance.Insight {
+	return A.flush(func(s *series) bool {
+		return !s.start.Add(A.window()).After(now)
+	})
+}
+
+// FlushAll emits the summaries of all windows, including the current ones
+func (A *Aggregator) FlushAll() []*appliance.Insight {
+	return A.flush(func(s *series) bool { return true })
+}
+
+func (A *Aggregator) flush(done func(s *series) bool) []*appliance.Insight {
+	A.mu.Lock()
+	flushed := []*series{}
+	for key, s := range A.series {
+		if done(s) {
+			flushed = append(flushed, s)
+			delete(A.series, key)
+		}
+	}
+	A.mu.Unlock()
+
+	sort.Slice(flushed, func(i, j int) bool {
+		a, b := flushed[i], flushed[j]
+		if !a.start.Equal(b.start) {
+			return a.start.Before(b.start)
+		}
+		if a.template.ApplianceId != b.template.ApplianceId {
+			return a.template.ApplianceId < b.template.ApplianceId
+		}
+		if a.template.Name != b.template.Name {
+			return a.template.Name < b.template.Name
+		}
+		if a.template.Metric.Unit != b.template.Metric.Unit {
+			return a.template.Metric.Unit < b.template.Metric.Unit
+		}
+		return canonicalKey(a.template.Dimensions) < canonicalKey(b.template.Dimensions)
+	})
+
+	insights := []*appliance.Insight{}
+	for _, s := range flushed {
+		insights = append(insights, A.summarize(s)...)
+	}
+	return insights
+}
+
+func (A *Aggregator) summarize(s *series) []*appliance.Insight {
+	unit := s.template.Metric.Unit
+	insights := []*appliance.Insight{
+		summary(s, "count", CountUnit, float64(s.count)),
+		summary(s, "sum", unit, s.sum),
+		summary(s, "min", unit, s.min),
+		summary(s, "max", unit, s.max),
+		summary(s, "last", unit, s.last),
+	}
+
+	sort.Float64s(s.samples)
+	for _, p := range A.Percentiles {
+		insights = append(insights, summary(s, percentileName(p), unit, percentile(s.samples, p)))
+	}
+	return insights
+}
+
+func summary(s *series, statistic string, unit string, value float64) *appliance.Insight {
+	return &appliance.Insight{
+		Name:        s.template.Name + "." + statistic,
+		Type:        s.template.Type,
+		ApplianceId: s.template.ApplianceId,
+		Metric:      &appliance.Metric{Unit: unit, Value: value},
+		Dimensions:  canonicalDimensions(s.template.Dimensions),
+		Timestamp:   appliance.JSONTime(s.start),
+	}
+}
+
+// percentile returns the nearest rank percentile p of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// percentileName formats 90 as p90 and 99.9 as p99.9
+func percentileName(p float64) string {
+	return "p" + strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.3f", p), "0"), ".")
+}
+
+// canonicalDimensions returns a copy of dimensions sorted by name and value
+func canonicalDimensions(dimensions []*appliance.Dimension) []*appliance.Dimension {
+	canonical := make([]*appliance.Dimension, 0, len(dimensions))
+	for _, dimension := range dimensions {
+		if dimension != nil {
+			copied := *dimension
+			canonical = append(canonical, &copied)
+		}
+	}
+	sort.Slice(canonical, func(i, j int) bool {
+		if canonical[i].Name != canonical[j].Name {
+			return canonical[i].Name < canonical[j].Name
+		}
+		return canonical[i].Value < canonical[j].Value
+	})
+	return canonical
+}
+
+func canonicalKey(dimensions []*appliance.Dimension) string {
+	var sb strings.Builder
+	for _, dimension := range canonicalDimensions(dimensions) {
+		sb.WriteString(fmt.Sprintf("%q=%q;", dimension.Name, dimension.Value))
+	}
+	return sb.String()
+}
+
+func (A *Aggregator) window() time.Duration {
+	if A.Window <= 0 {
+		return DefaultWindow
+	}
+	return A.Window
+}
+
+func (A *Aggregator) maxSamples() int {
+	if A.MaxSamples <= 0 {
+		return DefaultMaxSamples
+	}
+	return A.MaxSamples
+}
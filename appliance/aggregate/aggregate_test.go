@@ -0,0 +1,137 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package aggregate_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance/aggregate"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func sample(value float64, offset time.Duration, dimensions ...*appliance.Dimension) *appliance.Insight {
+	return &appliance.Insight{
+		Name:        "queue.size",
+		ApplianceId: "1",
+		Metric:      &appliance.Metric{Unit: "Count", Value: value},
+		Dimensions:  dimensions,
+		Timestamp:   appliance.JSONTime(start.Add(offset)),
+	}
+}
+
+func values(insights []*appliance.Insight) map[string]float64 {
+	result := map[string]float64{}
+	for _, insight := range insights {
+		result[insight.Name] = insight.Metric.Value
+	}
+	return result
+}
+
+func TestAggregateWindow(t *testing.T) {
+	assert := assert.New(t)
+	aggregator := aggregate.NewAggregator(time.Minute)
+	a := &appliance.Dimension{Name: "queue", Value: "a"}
+	b := &appliance.Dimension{Name: "host", Value: "b"}
+	for i := 1; i <= 100; i++ {
+		// dimension order must not split the series
+		if i%2 == 0 {
+			aggregator.Add(sample(float64(i), time.Duration(i)*100*time.Millisecond, a, b))
+		} else {
+			aggregator.Add(sample(float64(i), time.Duration(i)*100*time.Millisecond, b, a))
+		}
+	}
+	aggregator.Add(sample(7, 90*time.Second, a, b))
+
+	assert.Empty(aggregator.Flush(start.Add(59 * time.Second)))
+	insights := aggregator.Flush(start.Add(time.Minute))
+	assert.Len(insights, 8)
+	assert.Equal(map[string]float64{
+		"queue.size.count": 100,
+		"queue.size.sum":   5050,
+		"queue.size.min":   1,
+		"queue.size.max":   100,
+		"queue.size.last":  100,
+		"queue.size.p50":   50,
+		"queue.size.p90":   90,
+		"queue.size.p99":   99,
+	}, values(insights))
+	assert.Equal("host", insights[0].Dimensions[0].Name)
+	assert.Equal(start, time.Time(insights[0].Timestamp))
+
+	insights = aggregator.FlushAll()
+	assert.Equal(float64(1), values(insights)["queue.size.count"])
+	assert.Equal(start.Add(time.Minute), time.Time(insights[0].Timestamp))
+	assert.Empty(aggregator.FlushAll())
+}
+
+func TestAggregateUnitsAndPercentiles(t *testing.T) {
+	assert := assert.New(t)
+	aggregator := aggregate.NewAggregator(time.Minute)
+	aggregator.Percentiles = []float64{99.9}
+	aggregator.MaxSamples = 10
+
+	latency := sample(250, 0)
+	latency.Name = "latency"
+	latency.Metric.Unit = "Milliseconds"
+	aggregator.Add(latency, &appliance.Insight{Name: "no metric"})
+	for i := 0; i < 100; i++ {
+		aggregator.Add(sample(1, time.Second))
+	}
+
+	insights := aggregator.FlushAll()
+	assert.Len(insights, 12)
+	units := map[string]string{}
+	for _, insight := range insights {
+		units[insight.Name] = insight.Metric.Unit
+	}
+	assert.Equal("Count", units["latency.count"])
+	assert.Equal("Milliseconds", units["latency.p99.9"])
+	assert.Equal("Milliseconds", units["latency.max"])
+	assert.Equal(float64(1), values(insights)["queue.size.p99.9"])
+	assert.Equal(float64(100), values(insights)["queue.size.count"])
+}
+
+func TestAggregateFlushOrder(t *testing.T) {
+	assert := assert.New(t)
+	for i := 0; i < 10; i++ {
+		aggregator := aggregate.NewAggregator(time.Minute)
+		aggregator.Percentiles = nil
+		for _, id := range []string{"2", "1"} {
+			for _, unit := range []string{"Seconds", "Bytes"} {
+				insight := sample(1, 0)
+				insight.ApplianceId = id
+				insight.Metric.Unit = unit
+				aggregator.Add(insight)
+			}
+		}
+
+		order := []string{}
+		for _, insight := range aggregator.FlushAll() {
+			if insight.Name == "queue.size.sum" {
+				order = append(order, insight.ApplianceId+" "+insight.Metric.Unit)
+			}
+		}
+		assert.Equal([]string{"1 Bytes", "1 Seconds", "2 Bytes", "2 Seconds"}, order)
+	}
+}
+
+func TestAggregateZeroValue(t *testing.T) {
+	assert := assert.New(t)
+	aggregator := &aggregate.Aggregator{MaxSamples: 2}
+	for i := 1; i <= 10; i++ {
+		aggregator.Add(sample(float64(i), time.Duration(i)*time.Second))
+	}
+	insights := aggregator.FlushAll()
+	assert.Len(insights, 5)
+	assert.Equal(float64(10), values(insights)["queue.size.count"])
+	assert.Equal(float64(55), values(insights)["queue.size.sum"])
+}
@@ -0,0 +1,137 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliancetest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+// RunConformance checks the behaviour every appliance.Appliance must share.
+// factory gets a config saved to a temporary dir, it may fill in the type and
+// product specific values before creating the appliance. Configs are
+// encrypted with a key file in a temporary dir.
+func RunConformance(t *testing.T, factory appliance.ApplianceFactory) {
+	RunConformanceWithKeys(t, factory, nil)
+}
+
+// RunConformanceWithKeys is RunConformance with configs encrypted with keys,
+// a nil keys uses a key file in a temporary dir.
+func RunConformanceWithKeys(t *testing.T, factory appliance.ApplianceFactory, keys appliance.KeyProvider) {
+	t.Run("Identity", func(t *testing.T) {
+		assert := assert.New(t)
+		cfg, a := newAppliance(t, factory, keys)
+		assert.Equal(cfg.Id, a.Id())
+		assert.NotEmpty(a.Type())
+		assert.Equal(cfg.Dir, a.Dir())
+		assert.Equal(cfg.ServerDir, a.ServerDir())
+		assert.Equal(cfg.PublicKey, a.PublicKey())
+		assert.Equal(cfg.PrivateKey, a.PrivateKey())
+		assert.Equal(cfg.Password, a.Password())
+	})
+
+	t.Run("SaveReloadRoundTrip", func(t *testing.T) {
+		assert := assert.New(t)
+		cfg, a := newAppliance(t, factory, keys)
+		assert.NoError(a.UpdateSerialNumber("SN-conformance"))
+		assert.NoError(a.SaveConfigs())
+		assert.NoError(a.ReloadConfigs())
+		assert.Equal("SN-conformance", a.SerialNumber())
+		assert.Equal(cfg.Id, a.Id())
+		assert.Equal(cfg.Password, a.Password())
+		assert.Equal(cfg.Dir, a.Dir())
+
+		saved := reload(t, cfg)
+		assert.Equal("SN-conformance", saved.SerialNumber)
+		assert.Equal(cfg.Password, saved.Password)
+	})
+
+	t.Run("UpdatesPersist", func(t *testing.T) {
+		assert := assert.New(t)
+		cfg, a := newAppliance(t, factory, keys)
+		assert.NoError(a.UpdateSignUpStatus(true))
+		assert.NoError(a.UpdateRegistrationStatus(appliance.Registering))
+		assert.True(a.SignUpStatus())
+		assert.Equal(appliance.Registering, a.RegistrationStatus())
+
+		saved := reload(t, cfg)
+		assert.True(saved.SignUpStatus)
+		assert.Equal(appliance.Registering, saved.RegistrationStatus)
+	})
+
+	t.Run("InvalidRegistrationTransition", func(t *testing.T) {
+		assert := assert.New(t)
+		_, a := newAppliance(t, factory, keys)
+		assert.ErrorIs(a.UpdateRegistrationStatus(appliance.Deregistering), appliance.ErrInvalidTransition)
+		assert.Equal(appliance.NotRegistered, a.RegistrationStatus())
+	})
+
+	t.Run("CloneIsDeepCopy", func(t *testing.T) {
+		assert := assert.New(t)
+		_, a := newAppliance(t, factory, keys)
+		clone := a.Clone()
+		assert.NotSame(a, clone)
+		assert.Equal(a.Id(), clone.Id())
+		assert.Equal(a.Type(), clone.Type())
+
+		assert.NoError(clone.UpdateSerialNumber("SN-clone"))
+		assert.NoError(clone.UpdateRegistrationStatus(appliance.Registering))
+		assert.Empty(a.SerialNumber())
+		assert.Equal(appliance.NotRegistered, a.RegistrationStatus())
+	})
+
+	t.Run("RemoveDeletesConfig", func(t *testing.T) {
+		assert := assert.New(t)
+		cfg, a := newAppliance(t, factory, keys)
+		assert.NoError(a.Remove())
+		_, err := os.Stat(filepath.Join(cfg.Dir, appliance.ApplianceConfigName+"."+appliance.ApplianceConfigType))
+		assert.ErrorIs(err, os.ErrNotExist)
+	})
+}
+
+func newAppliance(t *testing.T, factory appliance.ApplianceFactory, keys appliance.KeyProvider) (*appliance.Config, appliance.Appliance) {
+	dir := t.TempDir()
+	if keys == nil {
+		keys = appliance.NewFileKeyProvider(filepath.Join(t.TempDir(), appliance.KeyFileName))
+	}
+	cfg := &appliance.Config{
+		Id:         "conformance",
+		ServerDir:  filepath.Join(dir, "server"),
+		PrivateKey: "private",
+		PublicKey:  "public",
+		Username:   "agent",
+		Password:   "password",
+		Keys:       keys,
+	}
+	if err := cfg.Save(dir); err != nil {
+		t.Fatalf("failed to save config: %s", err)
+	}
+	cfg.Dir = dir
+
+	a, err := factory(cfg)
+	if err != nil {
+		t.Fatalf("failed to create appliance: %s", err)
+	}
+	if err := a.SaveConfigs(); err != nil {
+		t.Fatalf("failed to save configs: %s", err)
+	}
+	return cfg, a
+}
+
+// reload reads the config saved for cfg, independent of the appliance
+func reload(t *testing.T, cfg *appliance.Config) *appliance.Config {
+	saved := &appliance.Config{Keys: cfg.Keys}
+	if err := saved.Reload(cfg.Dir); err != nil {
+		t.Fatalf("failed to reload config: %s", err)
+	}
+	return saved
+}
@@ -0,0 +1,198 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+// Package appliancetest provides an in-memory appliance and a conformance
+// suite for appliance.Appliance implementations
+package appliancetest
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+const FakeType = "Fake"
+
+// FakeAppliance is a configurable appliance.Appliance for tests. Set its
+// exported fields before sharing it between goroutines.
+type FakeAppliance struct {
+	*appliance.BaseAppliance
+
+	Endpoint         string
+	Headers          map[string]string
+	Connected        bool
+	License          bool
+	SelfManaged      bool
+	InsightList      []*appliance.Insight
+	NotificationList []*appliance.Notification
+	ApplianceInfo    *appliance.ApplianceInfo
+
+	// returned by all methods which can fail, except the ones of BaseAppliance
+	Err error
+
+	mu        sync.Mutex
+	published [][]*appliance.Insight
+	updates   int
+}
+
+// NewFakeAppliance returns a connected and licensed appliance for cfg, an
+// empty type is set to FakeType
+func NewFakeAppliance(cfg *appliance.Config) *FakeAppliance {
+	if len(cfg.Type) == 0 {
+		cfg.Type = FakeType
+	}
+	return &FakeAppliance{
+		BaseAppliance: appliance.NewBaseAppliance(cfg),
+		Endpoint:      "http://localhost:4040",
+		Headers:       map[string]string{},
+		Connected:     true,
+		License:       true,
+	}
+}
+
+// Factory creates fake appliances, it can be used as appliance.ApplianceFactory
+func Factory(cfg *appliance.Config) (appliance.Appliance, error) {
+	return NewFakeAppliance(cfg), nil
+}
+
+func (F *FakeAppliance) SignUp() error {
+	if F.Err != nil {
+		return F.Err
+	}
+	return F.UpdateSignUpStatus(true)
+}
+
+func (F *FakeAppliance) RemoveAccount() error {
+	if F.Err != nil {
+		return F.Err
+	}
+	return F.UpdateSignUpStatus(false)
+}
+
+func (F *FakeAppliance) ConnectInfo() (string, map[string]string, error) {
+	return F.Endpoint, F.Headers, F.Err
+}
+
+func (F *FakeAppliance) ConnectionStatus() bool {
+	return F.Connected
+}
+
+func (F *FakeAppliance) Insights() []*appliance.Insight {
+	return F.InsightList
+}
+
+func (F *FakeAppliance) HasLicense() bool {
+	return F.License
+}
+
+func (F *FakeAppliance) Notifications() []*appliance.Notification {
+	return F.NotificationList
+}
+
+func (F *FakeAppliance) Info() (*appliance.ApplianceInfo, error) {
+	if F.Err != nil {
+		return nil, F.Err
+	}
+	if F.ApplianceInfo != nil {
+		return F.ApplianceInfo, nil
+	}
+	return &appliance.ApplianceInfo{Type: F.Type(), ApplianceId: F.Id()}, nil
+}
+
+// Remove deletes the config from Dir
+func (F *FakeAppliance) Remove() error {
+	if F.Err != nil {
+		return F.Err
+	}
+	if len(F.Dir()) == 0 {
+		return nil
+	}
+	return appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, F.Dir()).Remove()
+}
+
+func (F *FakeAppliance) GetHardwareInfo() error {
+	return F.Err
+}
+
+func (F *FakeAppliance) InsightsPublished(insights []*appliance.Insight) {
+	F.mu.Lock()
+	defer F.mu.Unlock()
+	F.published = append(F.published, insights)
+}
+
+// Published returns the insights passed to InsightsPublished, one entry per call
+func (F *FakeAppliance) Published() [][]*appliance.Insight {
+	F.mu.Lock()
+	defer F.mu.Unlock()
+	return append([][]*appliance.Insight{}, F.published...)
+}
+
+// Clone returns a deep copy, insights published to the copy are not shared
+func (F *FakeAppliance) Clone() appliance.Appliance {
+	clone := &FakeAppliance{
+		BaseAppliance: F.CloneBase(),
+		Endpoint:      F.Endpoint,
+		Headers:       map[string]string{},
+		Connected:     F.Connected,
+		License:       F.License,
+		SelfManaged:   F.SelfManaged,
+		Err:           F.Err,
+	}
+	for name, value := range F.Headers {
+		clone.Headers[name] = value
+	}
+	for _, insight := range F.InsightList {
+		copied := *insight
+		clone.InsightList = append(clone.InsightList, &copied)
+	}
+	for _, notification := range F.NotificationList {
+		copied := *notification
+		clone.NotificationList = append(clone.NotificationList, &copied)
+	}
+	if F.ApplianceInfo != nil {
+		info := *F.ApplianceInfo
+		clone.ApplianceInfo = &info
+	}
+	return clone
+}
+
+func (F *FakeAppliance) IsSelfManagedInstallation() bool {
+	return F.SelfManaged
+}
+
+func (F *FakeAppliance) GetAppManagerUIBaseUrl() (string, error) {
+	return F.Endpoint, F.Err
+}
+
+func (F *FakeAppliance) GetApiServerBaseUrl() (string, error) {
+	return F.Endpoint, F.Err
+}
+
+func (F *FakeAppliance) ModifyApplianceResponse(r *http.Response) error {
+	return F.Err
+}
+
+func (F *FakeAppliance) HandleByLocalApi(r *http.Request) (*int, interface{}) {
+	return nil, nil
+}
+
+func (F *FakeAppliance) CheckUpdate() error {
+	F.mu.Lock()
+	F.updates++
+	F.mu.Unlock()
+	return F.Err
+}
+
+// UpdateChecks returns how many times CheckUpdate was called
+func (F *FakeAppliance) UpdateChecks() int {
+	F.mu.Lock()
+	defer F.mu.Unlock()
+	return F.updates
+}
+
+var _ appliance.Appliance = (*FakeAppliance)(nil)
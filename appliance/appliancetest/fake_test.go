@@ -0,0 +1,23 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliancetest_test
+
+import (
+	"testing"
+
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance/appliancetest"
+)
+
+func TestFakeApplianceConformance(t *testing.T) {
+	appliancetest.RunConformance(t, appliancetest.Factory)
+}
+
+func TestFakeApplianceConformanceWithKeys(t *testing.T) {
+	appliancetest.RunConformanceWithKeys(t, appliancetest.Factory, appliance.NewMachineKeyProvider("conformance"))
+}
@@ -0,0 +1,172 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"sync"
+)
+
+// BaseAppliance implements the parts of Appliance which only read or update
+// the Config. Products embed it and implement the remaining methods. It is
+// safe for concurrent use, updates are saved to Dir unless it is empty or
+// the BaseAppliance is a clone.
+type BaseAppliance struct {
+	mu           sync.RWMutex
+	config       *Config
+	registration *RegistrationStateMachine
+	// set on clones so they never save over the config they were copied from
+	noPersist bool
+}
+
+func NewBaseAppliance(cfg *Config) *BaseAppliance {
+	B := &BaseAppliance{config: cfg}
+	B.registration = NewRegistrationStateMachine(cfg, cfg.Dir)
+	B.registration.Locker = &B.mu
+	return B
+}
+
+// Config returns a copy of the current config
+func (B *BaseAppliance) Config() Config {
+	B.mu.RLock()
+	defer B.mu.RUnlock()
+	return *B.config
+}
+
+// Registration returns the state machine used by UpdateRegistrationStatus,
+// subscribe to it to be notified of registration status changes
+func (B *BaseAppliance) Registration() *RegistrationStateMachine {
+	return B.registration
+}
+
+func (B *BaseAppliance) Id() string {
+	B.mu.RLock()
+	defer B.mu.RUnlock()
+	return B.config.Id
+}
+
+func (B *BaseAppliance) Type() string {
+	B.mu.RLock()
+	defer B.mu.RUnlock()
+	return B.config.Type
+}
+
+func (B *BaseAppliance) Dir() string {
+	B.mu.RLock()
+	defer B.mu.RUnlock()
+	return B.config.Dir
+}
+
+func (B *BaseAppliance) ServerDir() string {
+	B.mu.RLock()
+	defer B.mu.RUnlock()
+	return B.config.ServerDir
+}
+
+func (B *BaseAppliance) PrivateKey() string {
+	B.mu.RLock()
+	defer B.mu.RUnlock()
+	return B.config.PrivateKey
+}
+
+func (B *BaseAppliance) PublicKey() string {
+	B.mu.RLock()
+	defer B.mu.RUnlock()
+	return B.config.PublicKey
+}
+
+func (B *BaseAppliance) Password() string {
+	B.mu.RLock()
+	defer B.mu.RUnlock()
+	return B.config.Password
+}
+
+func (B *BaseAppliance) SerialNumber() string {
+	B.mu.RLock()
+	defer B.mu.RUnlock()
+	return B.config.SerialNumber
+}
+
+func (B *BaseAppliance) SignUpStatus() bool {
+	B.mu.RLock()
+	defer B.mu.RUnlock()
+	return B.config.SignUpStatus
+}
+
+func (B *BaseAppliance) RegistrationStatus() Status {
+	return B.registration.Status()
+}
+
+// UpdateRegistrationStatus moves the registration to status, changes which are
+// not allowed by the registration state machine return a *TransitionError
+func (B *BaseAppliance) UpdateRegistrationStatus(status Status) error {
+	return B.registration.Transition(status, "")
+}
+
+func (B *BaseAppliance) UpdateSignUpStatus(status bool) error {
+	return B.UpdateConfig(func(cfg *Config) {
+		cfg.SignUpStatus = status
+	})
+}
+
+func (B *BaseAppliance) UpdateSerialNumber(serialNumber string) error {
+	return B.UpdateConfig(func(cfg *Config) {
+		cfg.SerialNumber = serialNumber
+	})
+}
+
+// UpdateConfig applies update to the config and saves it, the change is
+// reverted when saving fails
+func (B *BaseAppliance) UpdateConfig(update func(cfg *Config)) error {
+	B.mu.Lock()
+	defer B.mu.Unlock()
+	previous := *B.config
+	update(B.config)
+	if err := B.save(); err != nil {
+		*B.config = previous
+		return err
+	}
+	return nil
+}
+
+func (B *BaseAppliance) SaveConfigs() error {
+	B.mu.RLock()
+	defer B.mu.RUnlock()
+	return B.save()
+}
+
+func (B *BaseAppliance) ReloadConfigs() error {
+	B.mu.Lock()
+	defer B.mu.Unlock()
+	if len(B.config.Dir) == 0 {
+		return nil
+	}
+	cfg := Config{Dir: B.config.Dir, Keys: B.config.Keys}
+	if err := cfg.Reload(B.config.Dir); err != nil {
+		return err
+	}
+	*B.config = cfg
+	return nil
+}
+
+// CloneBase returns a BaseAppliance with a copy of the config, for use in
+// Clone of embedding appliances. The copy keeps Dir but changes to it are not
+// saved. Registration listeners are not copied.
+func (B *BaseAppliance) CloneBase() *BaseAppliance {
+	cfg := B.Config()
+	clone := &BaseAppliance{config: &cfg, noPersist: true}
+	clone.registration = NewRegistrationStateMachine(&cfg, "")
+	clone.registration.Locker = &clone.mu
+	return clone
+}
+
+func (B *BaseAppliance) save() error {
+	if B.noPersist || len(B.config.Dir) == 0 {
+		return nil
+	}
+	return B.config.Save(B.config.Dir)
+}
@@ -0,0 +1,76 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+func TestBaseAppliance(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	keys := testKeys(t)
+	base := appliance.NewBaseAppliance(&appliance.Config{Id: "1", Type: "Kerio-Connect", Password: "secret", Dir: dir, Keys: keys})
+
+	assert.Equal("1", base.Id())
+	assert.Equal("Kerio-Connect", base.Type())
+	assert.Equal(dir, base.Dir())
+	assert.Equal("secret", base.Password())
+
+	assert.NoError(base.UpdateSerialNumber("SN-1"))
+	assert.NoError(base.UpdateSignUpStatus(true))
+	assert.NoError(base.UpdateRegistrationStatus(appliance.Registering))
+	assert.ErrorIs(base.UpdateRegistrationStatus(appliance.Deregistering), appliance.ErrInvalidTransition)
+
+	reloaded := &appliance.Config{Keys: keys}
+	assert.NoError(reloaded.Reload(dir))
+	assert.Equal("SN-1", reloaded.SerialNumber)
+	assert.True(reloaded.SignUpStatus)
+	assert.Equal(appliance.Registering, reloaded.RegistrationStatus)
+	assert.Equal("secret", reloaded.Password)
+
+	reloaded.PublicKey = "public"
+	assert.NoError(reloaded.Save(dir))
+	assert.NoError(base.ReloadConfigs())
+	assert.Equal("public", base.PublicKey())
+	assert.Equal(dir, base.Dir())
+
+	clone := base.CloneBase()
+	assert.Equal(dir, clone.Dir())
+	assert.NoError(clone.UpdateRegistrationStatus(appliance.Registered))
+	assert.NoError(clone.UpdateSerialNumber("SN-2"))
+	assert.NoError(clone.SaveConfigs())
+	assert.Equal(appliance.Registering, base.RegistrationStatus())
+	assert.NoError(reloaded.Reload(dir))
+	assert.Equal("SN-1", reloaded.SerialNumber)
+	assert.Equal(appliance.Registering, reloaded.RegistrationStatus)
+}
+
+func TestBaseApplianceConcurrentUpdates(t *testing.T) {
+	base := appliance.NewBaseAppliance(&appliance.Config{Id: "1"})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = base.UpdateSignUpStatus(true)
+			_ = base.UpdateRegistrationStatus(appliance.Registering)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = base.SignUpStatus()
+			_ = base.RegistrationStatus()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, appliance.Registering, base.RegistrationStatus())
+}
@@ -0,0 +1,100 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrUnknownConfigType = errors.New("unknown config type")
+
+// Codec encodes and decodes configs of a ConfigManager.Type
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var codecsMutex sync.RWMutex
+var codecs = map[string]Codec{
+	"toml": &TOMLCodec{},
+	"json": &JSONCodec{},
+	"yaml": &YAMLCodec{},
+	"yml":  &YAMLCodec{},
+}
+
+// RegisterCodec makes codec available for config managers of configType,
+// replacing any codec registered for it before
+func RegisterCodec(configType string, codec Codec) {
+	codecsMutex.Lock()
+	defer codecsMutex.Unlock()
+	codecs[strings.ToLower(configType)] = codec
+}
+
+// UnregisterCodec removes the codec of configType
+func UnregisterCodec(configType string) {
+	codecsMutex.Lock()
+	defer codecsMutex.Unlock()
+	delete(codecs, strings.ToLower(configType))
+}
+
+func LookupCodec(configType string) (Codec, error) {
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	codec, ok := codecs[strings.ToLower(configType)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownConfigType, configType)
+	}
+	return codec, nil
+}
+
+type TOMLCodec struct{}
+
+func (T *TOMLCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (T *TOMLCodec) Unmarshal(data []byte, v interface{}) error {
+	_, err := toml.Decode(string(data), v)
+	return err
+}
+
+type JSONCodec struct{}
+
+func (J *JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func (J *JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type YAMLCodec struct{}
+
+func (Y *YAMLCodec) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (Y *YAMLCodec) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
@@ -0,0 +1,119 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+type sidecarConfig struct {
+	Port   int    `json:"port" yaml:"port"`
+	Secret string `json:"secret" yaml:"secret" secret:"true"`
+}
+
+func TestConfigManagerCodecs(t *testing.T) {
+	for _, configType := range []string{"toml", "json", "yaml"} {
+		t.Run(configType, func(t *testing.T) {
+			assert := assert.New(t)
+			cfgMgr := appliance.NewConfigManager("sidecar", configType, t.TempDir())
+			cfgMgr.Keys = appliance.NewMachineKeyProvider("machine-a")
+
+			assert.NoError(cfgMgr.Save(&sidecarConfig{Port: 4040, Secret: "token"}))
+			loaded := &sidecarConfig{}
+			assert.NoError(cfgMgr.Unmarshal(loaded))
+			assert.Equal(sidecarConfig{Port: 4040, Secret: "token"}, *loaded)
+		})
+	}
+}
+
+func TestJSONConfigIsReadable(t *testing.T) {
+	assert := assert.New(t)
+	cfgMgr := appliance.NewConfigManager("sidecar", "json", t.TempDir())
+	assert.NoError(os.WriteFile(cfgMgr.FullPath(), []byte(`{"port": 8080}`), 0644))
+
+	loaded := &sidecarConfig{}
+	assert.NoError(cfgMgr.Unmarshal(loaded))
+	assert.Equal(8080, loaded.Port)
+
+	var content map[string]interface{}
+	assert.NoError(cfgMgr.Save(loaded))
+	data, err := os.ReadFile(cfgMgr.FullPath())
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(data, &content))
+	assert.Equal(float64(8080), content["port"])
+}
+
+func TestConfigKeysInEveryCodec(t *testing.T) {
+	for _, configType := range []string{"toml", "json", "yaml"} {
+		t.Run(configType, func(t *testing.T) {
+			assert := assert.New(t)
+			cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, configType, t.TempDir())
+			cfgMgr.Keys = appliance.NewMachineKeyProvider("machine-a")
+			config := &appliance.Config{Id: "1", ServerDir: "/opt/kerio", Password: "secret", Dir: "/tmp/appliance"}
+			assert.NoError(cfgMgr.SaveApplianceConfig(config))
+
+			content := map[string]interface{}{}
+			data, err := os.ReadFile(cfgMgr.FullPath())
+			assert.NoError(err)
+			codec, err := appliance.LookupCodec(configType)
+			assert.NoError(err)
+			assert.NoError(codec.Unmarshal(data, &content))
+			assert.Equal("1", content["id"])
+			assert.Equal("/opt/kerio", content["serverDir"])
+			assert.NotEmpty(content["passwordEncrypted"])
+			for _, key := range []string{"Id", "ServerDir", "Dir", "Password", "Keys"} {
+				assert.NotContains(content, key)
+			}
+			if configType != "toml" {
+				assert.NotContains(content, "password")
+			}
+			assert.NotContains(string(data), "secret")
+			assert.NotContains(string(data), "/tmp/appliance")
+
+			loaded := &appliance.Config{}
+			assert.NoError(cfgMgr.LoadApplianceConfig(loaded))
+			assert.Equal("secret", loaded.Password)
+
+			// a password set by hand is encrypted on load
+			content["password"] = "changed"
+			delete(content, "passwordEncrypted")
+			data, err = codec.Marshal(content)
+			assert.NoError(err)
+			assert.NoError(os.WriteFile(cfgMgr.FullPath(), data, 0600))
+			loaded = &appliance.Config{}
+			assert.NoError(cfgMgr.LoadApplianceConfig(loaded))
+			assert.Equal("changed", loaded.Password)
+			data, err = os.ReadFile(cfgMgr.FullPath())
+			assert.NoError(err)
+			assert.NotContains(string(data), "changed")
+		})
+	}
+}
+
+type customCodec struct {
+	appliance.JSONCodec
+}
+
+func TestRegisterCodec(t *testing.T) {
+	assert := assert.New(t)
+	cfgMgr := appliance.NewConfigManager("sidecar", "custom", t.TempDir())
+	assert.ErrorIs(cfgMgr.Save(&sidecarConfig{}), appliance.ErrUnknownConfigType)
+
+	appliance.RegisterCodec("custom", &customCodec{})
+	t.Cleanup(func() { appliance.UnregisterCodec("custom") })
+	assert.NoError(cfgMgr.Save(&sidecarConfig{Port: 1}))
+
+	codec, err := appliance.LookupCodec("CUSTOM")
+	assert.NoError(err)
+	assert.IsType(&customCodec{}, codec)
+}
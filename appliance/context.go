@@ -0,0 +1,72 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var ErrContextMismatch = errors.New("encrypted value belongs to a different context")
+
+// EncryptionContext is authenticated as GCM additional data, so a value
+// encrypted for one appliance or field cannot be decrypted for another one
+type EncryptionContext struct {
+	ApplianceId   string
+	ApplianceType string
+	Field         string
+}
+
+// ContextBinder is implemented by configs whose encrypted values should be
+// bound to the identity of the appliance they belong to
+type ContextBinder interface {
+	EncryptionContext() EncryptionContext
+}
+
+func (E EncryptionContext) WithField(field string) EncryptionContext {
+	E.Field = field
+	return E
+}
+
+// AdditionalData returns the canonical encoding of the context, every value
+// is length prefixed so different contexts never encode to the same bytes
+func (E EncryptionContext) AdditionalData() []byte {
+	data := []byte("gfienc-context/v1")
+	for _, value := range []string{E.ApplianceId, E.ApplianceType, E.Field} {
+		data = binary.BigEndian.AppendUint32(data, uint32(len(value)))
+		data = append(data, value...)
+	}
+	return data
+}
+
+func (E EncryptionContext) String() string {
+	return fmt.Sprintf("{applianceId=%s, applianceType=%s, field=%s}", E.ApplianceId, E.ApplianceType, E.Field)
+}
+
+// ContextMismatchError is returned when a value is decrypted with a context
+// other than the one it was encrypted with
+type ContextMismatchError struct {
+	Context EncryptionContext
+}
+
+func (E *ContextMismatchError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrContextMismatch, E.Context.String())
+}
+
+func (E *ContextMismatchError) Unwrap() error {
+	return ErrContextMismatch
+}
+
+// contextOf returns the context config binds its values to, if any
+func contextOf(config interface{}) EncryptionContext {
+	if binder, ok := config.(ContextBinder); ok {
+		return binder.EncryptionContext()
+	}
+	return EncryptionContext{}
+}
@@ -0,0 +1,92 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+func TestConfigValuesBoundToAppliance(t *testing.T) {
+	assert := assert.New(t)
+	keys := appliance.NewMachineKeyProvider("machine-a")
+
+	first := &appliance.Config{Id: "first", Type: "Kerio-Connect", Password: "first-password", PrivateKey: "first-key", Keys: keys}
+	firstDir := t.TempDir()
+	assert.NoError(first.Save(firstDir))
+
+	second := &appliance.Config{Id: "second", Type: "Kerio-Connect", Password: "second-password", Keys: keys}
+	secondDir := t.TempDir()
+	assert.NoError(second.Save(secondDir))
+
+	reloaded := &appliance.Config{Keys: keys}
+	assert.NoError(reloaded.Reload(firstDir))
+	assert.Equal("first-password", reloaded.Password)
+	assert.Equal("first-key", reloaded.PrivateKey)
+
+	// copy the encrypted values of the first appliance into the second config
+	stored := &appliance.Config{}
+	cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, firstDir)
+	cfgMgr.Keys = keys
+	assert.NoError(cfgMgr.Unmarshal(stored))
+	copied := &appliance.Config{Id: "second", Type: "Kerio-Connect", PasswordEncrypted: stored.PasswordEncrypted}
+	assert.NoError(appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, secondDir).Save(copied))
+
+	err := (&appliance.Config{Keys: keys}).Reload(secondDir)
+	assert.ErrorIs(err, appliance.ErrContextMismatch)
+
+	var mismatch *appliance.ContextMismatchError
+	assert.True(errors.As(err, &mismatch))
+	assert.Equal("second", mismatch.Context.ApplianceId)
+	assert.Equal("Password", mismatch.Context.Field)
+}
+
+func TestOpenEnvelopeWithContext(t *testing.T) {
+	assert := assert.New(t)
+	keys := appliance.NewMachineKeyProvider("machine-a")
+	context := appliance.EncryptionContext{ApplianceId: "1", ApplianceType: "Kerio-Connect", Field: "Token"}
+
+	sealed, err := appliance.SealEnvelopeWithContext("secret", keys, context)
+	assert.NoError(err)
+
+	plaintext, stale, err := appliance.OpenEnvelopeWithContext(sealed, keys, context)
+	assert.NoError(err)
+	assert.False(stale)
+	assert.Equal("secret", plaintext)
+
+	_, _, err = appliance.OpenEnvelopeWithContext(sealed, keys, context.WithField("Password"))
+	assert.ErrorIs(err, appliance.ErrContextMismatch)
+}
+
+func TestDecryptPasswordFor(t *testing.T) {
+	assert := assert.New(t)
+	previous := appliance.DefaultKeyProvider
+	appliance.DefaultKeyProvider = appliance.NewMachineKeyProvider("machine-a")
+	t.Cleanup(func() { appliance.DefaultKeyProvider = previous })
+
+	dir := t.TempDir()
+	config := &appliance.Config{Id: "1", Type: "Kerio-Connect", Password: "secret"}
+	assert.NoError(config.Save(dir))
+	stored := &appliance.Config{}
+	assert.NoError(appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, dir).Unmarshal(stored))
+
+	_, err := appliance.DecryptPassword(stored.PasswordEncrypted)
+	assert.ErrorIs(err, appliance.ErrContextMismatch)
+	password, err := appliance.DecryptPasswordFor(config.EncryptionContext(), stored.PasswordEncrypted)
+	assert.NoError(err)
+	assert.Equal("secret", password)
+
+	encrypted, err := appliance.EncryptPasswordFor(config.EncryptionContext(), "other")
+	assert.NoError(err)
+	password, err = appliance.DecryptPasswordFor(config.EncryptionContext(), encrypted)
+	assert.NoError(err)
+	assert.Equal("other", password)
+}
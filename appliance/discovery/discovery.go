@@ -0,0 +1,257 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package discovery
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance/types"
+	"github.com/trilogy-group/gfi-agent-sdk/constants"
+	"github.com/trilogy-group/gfi-agent-sdk/logger"
+	"github.com/trilogy-group/gfi-agent-sdk/utils"
+)
+
+const (
+	// config file of the product found
+	ConfidenceConfigFound = 0.7
+	// config file found and product version read
+	ConfidenceVersionFound = 1.0
+)
+
+// Match is an installation found by a Detector
+type Match struct {
+	Type       string
+	ServerDir  string
+	Version    string
+	Confidence float64
+}
+
+func (M *Match) String() string {
+	return fmt.Sprintf("{type=%s, serverDir=%s, version=%s, confidence=%.2f}", M.Type, M.ServerDir, M.Version, M.Confidence)
+}
+
+// Config returns a config proposing the installation for registration
+func (M *Match) Config() *appliance.Config {
+	return &appliance.Config{Type: M.Type, ServerDir: M.ServerDir}
+}
+
+// Detector is implemented by appliance types which can find their installations
+type Detector interface {
+	// install roots to probe on this machine
+	Roots() []string
+
+	// returns the installation at root or nil when there is none
+	Probe(root string) (*Match, error)
+}
+
+// Discover runs the detectors of all registered appliance types
+func Discover() ([]*Match, error) {
+	detectors := map[string]Detector{}
+	for _, type_ := range types.Registered() {
+		if detector, ok := type_.(Detector); ok {
+			detectors[type_.Name()] = detector
+		}
+	}
+	return Run(detectors)
+}
+
+// Run probes all roots of the detectors, keyed by appliance type name.
+// Matches are ordered by confidence, each server dir is reported once per
+// type and probe failures are returned together with the matches found.
+func Run(detectors map[string]Detector) ([]*Match, error) {
+	names := make([]string, 0, len(detectors))
+	for name := range detectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	matches := []*Match{}
+	seen := map[string]*Match{}
+	errs := []error{}
+	for _, name := range names {
+		for _, root := range detectors[name].Roots() {
+			if !utils.FS.FileExists(root) {
+				continue
+			}
+			match, err := detectors[name].Probe(root)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to probe %s for %s: %w", root, name, err))
+				continue
+			}
+			if match == nil {
+				continue
+			}
+			if len(match.Type) == 0 {
+				match.Type = name
+			}
+
+			key := strings.ToLower(match.Type) + "|" + filepath.Clean(match.ServerDir)
+			if existing, ok := seen[key]; ok {
+				if match.Confidence > existing.Confidence {
+					*existing = *match
+				}
+				continue
+			}
+			seen[key] = match
+			matches = append(matches, match)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Confidence > matches[j].Confidence })
+	return matches, errors.Join(errs...)
+}
+
+// ConfigFileDetector finds installations by the config file the product
+// keeps relative to its server dir
+type ConfigFileDetector struct {
+	TypeName   string
+	Candidates []string
+	ConfigPath string
+
+	// reads the product version from the server dir, optional
+	Version func(serverDir string) (string, error)
+}
+
+// NewKerioConnectDetector probes roots, or the default install dir when none
+// are given, and reads the version from mailserver.cfg
+func NewKerioConnectDetector(typeName string, roots ...string) *ConfigFileDetector {
+	return &ConfigFileDetector{
+		TypeName:   typeName,
+		Candidates: candidates(roots, constants.KerioConnectInstallDir),
+		ConfigPath: constants.KerioConnectServerConfigPath,
+		Version:    KerioVersion(constants.KerioConnectServerConfigPath),
+	}
+}
+
+// NewKerioControlDetector probes roots, or the default install dir when none
+// are given, and reads the version from winroute.cfg
+func NewKerioControlDetector(typeName string, roots ...string) *ConfigFileDetector {
+	return &ConfigFileDetector{
+		TypeName:   typeName,
+		Candidates: candidates(roots, constants.KerioControlInstallDir),
+		ConfigPath: constants.KerioControlServerConfigPath,
+		Version:    KerioVersion(constants.KerioControlServerConfigPath),
+	}
+}
+
+// NewLanguardDetector probes roots, or the default install dir when none are
+// given, and reads the version from restapi.cfg
+func NewLanguardDetector(typeName string, roots ...string) *ConfigFileDetector {
+	return &ConfigFileDetector{
+		TypeName:   typeName,
+		Candidates: candidates(roots, constants.GFILanguardInstallDir),
+		ConfigPath: constants.GFILanguardAPIConfigPath,
+		Version:    LanguardVersion,
+	}
+}
+
+func candidates(roots []string, defaultRoot string) []string {
+	if len(roots) > 0 {
+		return roots
+	}
+	if len(defaultRoot) == 0 {
+		return []string{}
+	}
+	return []string{defaultRoot}
+}
+
+// KerioVersion returns a version reader of the Version variable in the Kerio
+// config file at configPath relative to the server dir
+func KerioVersion(configPath string) func(serverDir string) (string, error) {
+	return func(serverDir string) (string, error) {
+		file, err := os.Open(filepath.Join(serverDir, configPath))
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+
+		decoder := xml.NewDecoder(file)
+		for {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				return "", nil
+			}
+			if err != nil {
+				return "", err
+			}
+			element, ok := token.(xml.StartElement)
+			if !ok || element.Name.Local != "variable" || !hasName(element, "Version") {
+				continue
+			}
+			var version string
+			if err := decoder.DecodeElement(&version, &element); err != nil {
+				return "", err
+			}
+			return strings.TrimSpace(version), nil
+		}
+	}
+}
+
+func hasName(element xml.StartElement, name string) bool {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == "name" && strings.EqualFold(attr.Value, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// LanguardVersion reads the version entry of restapi.cfg, a file of
+// key = value lines
+func LanguardVersion(serverDir string) (string, error) {
+	file, err := os.Open(filepath.Join(serverDir, constants.GFILanguardAPIConfigPath))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "version") {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+func (C *ConfigFileDetector) Roots() []string {
+	return C.Candidates
+}
+
+func (C *ConfigFileDetector) Probe(root string) (*Match, error) {
+	if !utils.FS.FileExists(filepath.Join(root, C.ConfigPath)) {
+		return nil, nil
+	}
+
+	match := &Match{Type: C.TypeName, ServerDir: root, Confidence: ConfidenceConfigFound}
+	if C.Version == nil {
+		return match, nil
+	}
+
+	version, err := C.Version(root)
+	if err != nil {
+		// the installation is still there, only less certain
+		logger.Logger.Warningf("Failed to read %s version from %s: %s", C.TypeName, root, err)
+		return match, nil
+	}
+	if len(version) > 0 {
+		match.Version = version
+		match.Confidence = ConfidenceVersionFound
+	}
+	return match, nil
+}
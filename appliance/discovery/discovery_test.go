@@ -0,0 +1,125 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package discovery_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance/discovery"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance/types"
+	"github.com/trilogy-group/gfi-agent-sdk/constants"
+)
+
+func createFile(t *testing.T, path string, content string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestRun(t *testing.T) {
+	assert := assert.New(t)
+	root := t.TempDir()
+	connectRoot := filepath.Join(root, "kerio")
+	controlRoot := filepath.Join(root, "winroute")
+	createFile(t, filepath.Join(connectRoot, constants.KerioConnectServerConfigPath), "<config/>")
+	createFile(t, filepath.Join(connectRoot, "version"), "10.0.5\n")
+	createFile(t, filepath.Join(controlRoot, constants.KerioControlServerConfigPath), "<config/>")
+
+	connect := discovery.NewKerioConnectDetector("Kerio-Connect", filepath.Join(root, "missing"), connectRoot, connectRoot)
+	connect.Version = func(serverDir string) (string, error) {
+		data, err := os.ReadFile(filepath.Join(serverDir, "version"))
+		if err != nil {
+			return "", err
+		}
+		return string(data[:len(data)-1]), nil
+	}
+	control := discovery.NewKerioControlDetector("Kerio-Control", controlRoot, connectRoot)
+
+	matches, err := discovery.Run(map[string]discovery.Detector{"Kerio-Connect": connect, "Kerio-Control": control})
+	assert.NoError(err)
+	assert.Equal([]*discovery.Match{
+		{Type: "Kerio-Connect", ServerDir: connectRoot, Version: "10.0.5", Confidence: discovery.ConfidenceVersionFound},
+		{Type: "Kerio-Control", ServerDir: controlRoot, Confidence: discovery.ConfidenceConfigFound},
+	}, matches)
+
+	config := matches[0].Config()
+	assert.Equal("Kerio-Connect", config.Type)
+	assert.Equal(connectRoot, config.ServerDir)
+}
+
+type failingDetector struct{ root string }
+
+func (F *failingDetector) Roots() []string { return []string{F.root} }
+func (F *failingDetector) Probe(root string) (*discovery.Match, error) {
+	return nil, errors.New("access denied")
+}
+
+type languardType struct {
+	*discovery.ConfigFileDetector
+}
+
+func (L *languardType) Name() string       { return "Languard" }
+func (L *languardType) Synonyms() []string { return nil }
+
+func TestDiscoverRegisteredTypes(t *testing.T) {
+	assert := assert.New(t)
+	root := t.TempDir()
+	createFile(t, filepath.Join(root, constants.GFILanguardAPIConfigPath), "")
+	assert.NoError(types.Register(&languardType{discovery.NewLanguardDetector("", root)}))
+	t.Cleanup(func() { types.Unregister("Languard") })
+
+	matches, err := discovery.Discover()
+	assert.NoError(err)
+	if assert.Len(matches, 1) {
+		assert.Equal("Languard", matches[0].Type)
+		assert.Equal(root, matches[0].ServerDir)
+	}
+
+	_, err = discovery.Run(map[string]discovery.Detector{"Broken": &failingDetector{root: root}})
+	assert.ErrorContains(err, "access denied")
+}
+
+func TestVersionReaders(t *testing.T) {
+	assert := assert.New(t)
+	root := t.TempDir()
+	createFile(t, filepath.Join(root, constants.KerioControlServerConfigPath),
+		`<?xml version="1.0"?><config><variable name="Hostname">gw</variable><variable name="Version"> 9.4.3 </variable></config>`)
+	createFile(t, filepath.Join(root, constants.GFILanguardAPIConfigPath), "port = 1072\nVersion = 12.9\n")
+
+	match, err := discovery.NewKerioControlDetector("Kerio-Control", root).Probe(root)
+	assert.NoError(err)
+	assert.Equal(&discovery.Match{Type: "Kerio-Control", ServerDir: root, Version: "9.4.3", Confidence: discovery.ConfidenceVersionFound}, match)
+
+	match, err = discovery.NewLanguardDetector("Languard", root).Probe(root)
+	assert.NoError(err)
+	assert.Equal("12.9", match.Version)
+
+	// unreadable versions lower the confidence only
+	createFile(t, filepath.Join(root, constants.KerioConnectServerConfigPath), "<config>")
+	match, err = discovery.NewKerioConnectDetector("Kerio-Connect", root).Probe(root)
+	assert.NoError(err)
+	assert.Equal(discovery.ConfidenceConfigFound, match.Confidence)
+}
+
+func TestDefaultRoots(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(nonEmpty(constants.KerioConnectInstallDir), discovery.NewKerioConnectDetector("Kerio-Connect").Roots())
+	assert.Equal(nonEmpty(constants.KerioControlInstallDir), discovery.NewKerioControlDetector("Kerio-Control").Roots())
+	assert.Equal(nonEmpty(constants.GFILanguardInstallDir), discovery.NewLanguardDetector("Languard").Roots())
+	assert.Equal([]string{"root"}, discovery.NewLanguardDetector("Languard", "root").Roots())
+}
+
+func nonEmpty(root string) []string {
+	if len(root) == 0 {
+		return []string{}
+	}
+	return []string{root}
+}
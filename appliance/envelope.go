@@ -0,0 +1,244 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/trilogy-group/gfi-agent-sdk/logger"
+)
+
+// Encrypted values are stored as
+//
+//	gfienc:<version>:<algorithm>:<key id>:<base64(nonce||ciphertext)>
+//
+// so the key used for a value can be found, and values encrypted with a
+// retired key or in the legacy plain base64 format can be re-encrypted.
+// Version 2 values are bound to an EncryptionContext.
+const (
+	EnvelopePrefix     = "gfienc"
+	EnvelopeVersion1   = 1
+	EnvelopeVersion2   = 2
+	AlgorithmAES256GCM = "A256GCM"
+)
+
+var (
+	ErrNotEnvelope        = errors.New("value is not an encrypted envelope")
+	ErrUnknownKey         = errors.New("value is encrypted with an unknown key")
+	ErrUnsupportedVersion = errors.New("unsupported envelope version")
+)
+
+type Envelope struct {
+	Version    int
+	Algorithm  string
+	KeyId      string
+	Ciphertext []byte
+}
+
+func (E *Envelope) String() string {
+	return strings.Join([]string{
+		EnvelopePrefix,
+		strconv.Itoa(E.Version),
+		E.Algorithm,
+		E.KeyId,
+		base64.StdEncoding.EncodeToString(E.Ciphertext),
+	}, ":")
+}
+
+func IsEnvelope(value string) bool {
+	return strings.HasPrefix(value, EnvelopePrefix+":")
+}
+
+func ParseEnvelope(value string) (*Envelope, error) {
+	if !IsEnvelope(value) {
+		return nil, ErrNotEnvelope
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) != 5 {
+		return nil, errors.New("malformed envelope")
+	}
+
+	version, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed envelope version")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, errors.New("malformed envelope ciphertext")
+	}
+
+	return &Envelope{
+		Version:    version,
+		Algorithm:  parts[2],
+		KeyId:      parts[3],
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// KeyId returns a short fingerprint identifying the key without revealing it
+func KeyId(key *[32]byte) string {
+	sum := sha256.Sum256(key[:])
+	return hex.EncodeToString(sum[:8])
+}
+
+// KeyRing is implemented by key providers which keep retired keys around, so
+// values encrypted with them can still be decrypted and then re-encrypted
+// with the current key
+type KeyRing interface {
+	KeyProvider
+	RetiredKeys() ([]*[32]byte, error)
+}
+
+// RotatingKeyProvider encrypts with Current and decrypts with Current or any
+// of the Retired providers
+type RotatingKeyProvider struct {
+	Current KeyProvider
+	Retired []KeyProvider
+}
+
+func NewRotatingKeyProvider(current KeyProvider, retired ...KeyProvider) *RotatingKeyProvider {
+	return &RotatingKeyProvider{Current: current, Retired: retired}
+}
+
+func (R *RotatingKeyProvider) Key() (*[32]byte, error) {
+	return R.Current.Key()
+}
+
+// RetiredKeys returns the keys of the retired providers. Providers failing to
+// return a key are skipped, their errors are joined and returned along with
+// the keys of the others.
+func (R *RotatingKeyProvider) RetiredKeys() ([]*[32]byte, error) {
+	keys := []*[32]byte{}
+	errs := []error{}
+	for _, provider := range R.Retired {
+		key, err := provider.Key()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, errors.Join(errs...)
+}
+
+// SealEnvelope encrypts value with the current key of the provider
+func SealEnvelope(value string, keys KeyProvider) (string, error) {
+	return SealEnvelopeWithContext(value, keys, EncryptionContext{})
+}
+
+// OpenEnvelope decrypts value written by SealEnvelope or by older versions of
+// the sdk. stale is true when the value should be re-encrypted, because it
+// uses an older format or a key other than the current one.
+func OpenEnvelope(value string, keys KeyProvider) (plaintext string, stale bool, err error) {
+	return OpenEnvelopeWithContext(value, keys, EncryptionContext{})
+}
+
+// SealEnvelopeWithContext encrypts value with the current key of the provider
+// and binds it to context, so it can only be decrypted with the same context
+func SealEnvelopeWithContext(value string, keys KeyProvider, context EncryptionContext) (string, error) {
+	key, err := keys.Key()
+	if err != nil {
+		return "", errors.New("failed to get encryption key: " + err.Error())
+	}
+
+	encrypted, err := EncryptWithAdditionalData([]byte(value), key, context.AdditionalData())
+	if err != nil {
+		return "", errors.New("failed to encrypt config data: " + err.Error())
+	}
+
+	envelope := &Envelope{
+		Version:    EnvelopeVersion2,
+		Algorithm:  AlgorithmAES256GCM,
+		KeyId:      KeyId(key),
+		Ciphertext: encrypted,
+	}
+	return envelope.String(), nil
+}
+
+// OpenEnvelopeWithContext decrypts value written by SealEnvelopeWithContext.
+// A *ContextMismatchError is returned when value was bound to another context.
+// Values written before contexts were introduced are accepted and reported
+// as stale, so they get bound on the next save.
+func OpenEnvelopeWithContext(value string, keys KeyProvider, context EncryptionContext) (plaintext string, stale bool, err error) {
+	candidates, current := decryptionKeys(keys)
+
+	if !IsEnvelope(value) {
+		for _, key := range candidates {
+			if decrypted, err := decryptLegacy(value, key); err == nil {
+				return decrypted, true, nil
+			}
+		}
+		return "", false, errors.New("failed to decrypt config data")
+	}
+
+	envelope, err := ParseEnvelope(value)
+	if err != nil {
+		return "", false, err
+	}
+	if envelope.Version != EnvelopeVersion1 && envelope.Version != EnvelopeVersion2 {
+		return "", false, fmt.Errorf("%w: %d", ErrUnsupportedVersion, envelope.Version)
+	}
+	if envelope.Algorithm != AlgorithmAES256GCM {
+		return "", false, fmt.Errorf("unsupported envelope algorithm: %s", envelope.Algorithm)
+	}
+
+	for _, key := range candidates {
+		if KeyId(key) != envelope.KeyId {
+			continue
+		}
+
+		if envelope.Version == EnvelopeVersion1 {
+			decrypted, err := Decrypt(envelope.Ciphertext, key)
+			if err != nil {
+				return "", false, errors.New("failed to further decode config data")
+			}
+			return string(decrypted), true, nil
+		}
+
+		decrypted, err := DecryptWithAdditionalData(envelope.Ciphertext, key, context.AdditionalData())
+		if err != nil {
+			// the key is known, so the value belongs elsewhere or was tampered with
+			return "", false, &ContextMismatchError{Context: context}
+		}
+		return string(decrypted), current == nil || KeyId(current) != envelope.KeyId, nil
+	}
+
+	return "", false, fmt.Errorf("%w: %s", ErrUnknownKey, envelope.KeyId)
+}
+
+// decryptionKeys returns the current key followed by retired keys and the
+// legacy built-in key. Providers failing to return a key are skipped, so
+// values encrypted with the legacy key stay readable.
+func decryptionKeys(keys KeyProvider) ([]*[32]byte, *[32]byte) {
+	candidates := []*[32]byte{}
+
+	current, err := keys.Key()
+	if err == nil {
+		candidates = append(candidates, current)
+	} else {
+		current = nil
+	}
+
+	if ring, ok := keys.(KeyRing); ok {
+		retired, err := ring.RetiredKeys()
+		if err != nil {
+			logger.Logger.Warningf("Failed to get retired encryption keys: %s", err)
+		}
+		candidates = append(candidates, retired...)
+	}
+
+	return append(candidates, &encryptionKey), current
+}
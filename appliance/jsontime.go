@@ -0,0 +1,265 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// TimeFormat is the wire format of JSONTime values
+type TimeFormat int32
+
+const (
+	TimeFormatRFC3339Nano TimeFormat = iota
+	TimeFormatEpochMillis
+	TimeFormatEpochSeconds
+)
+
+// epoch numbers of at least this magnitude are taken as milliseconds, as
+// seconds they would be past the year 5000
+const epochMillisThreshold = 1e11
+
+var timeFormat int32 = int32(TimeFormatRFC3339Nano)
+
+// SetTimeFormat selects the default format JSONTime values are marshalled in,
+// all formats are accepted when unmarshalling. Encoders which need a fixed
+// format use MarshalJSONWithTimeFormat instead.
+func SetTimeFormat(format TimeFormat) {
+	atomic.StoreInt32(&timeFormat, int32(format))
+}
+
+func GetTimeFormat() TimeFormat {
+	return TimeFormat(atomic.LoadInt32(&timeFormat))
+}
+
+func (T JSONTime) Time() time.Time {
+	return time.Time(T)
+}
+
+func (T JSONTime) IsZero() bool {
+	return time.Time(T).IsZero()
+}
+
+func (T JSONTime) String() string {
+	return time.Time(T).Format(time.RFC3339Nano)
+}
+
+// Format returns the value in format, numbers for the epoch formats and an
+// empty string for the zero time
+func (T JSONTime) Format(format TimeFormat) string {
+	t := time.Time(T)
+	if t.IsZero() {
+		return ""
+	}
+	switch format {
+	case TimeFormatEpochMillis:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case TimeFormatEpochSeconds:
+		return strconv.FormatInt(t.Unix(), 10)
+	default:
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+// MarshalJSON writes a string or a number depending on GetTimeFormat, the
+// zero time is null
+func (T JSONTime) MarshalJSON() ([]byte, error) {
+	if T.IsZero() {
+		return []byte("null"), nil
+	}
+	format := GetTimeFormat()
+	if format == TimeFormatRFC3339Nano {
+		return json.Marshal(T.Format(format))
+	}
+	return []byte(T.Format(format)), nil
+}
+
+func (T *JSONTime) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		*T = JSONTime{}
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		return T.UnmarshalText([]byte(value))
+	}
+	return T.UnmarshalText(data)
+}
+
+func (T JSONTime) MarshalText() ([]byte, error) {
+	return []byte(T.Format(GetTimeFormat())), nil
+}
+
+// UnmarshalText accepts RFC 3339 times and epoch seconds or milliseconds,
+// which may have a fraction
+func (T *JSONTime) UnmarshalText(text []byte) error {
+	value := strings.TrimSpace(string(text))
+	if len(value) == 0 {
+		*T = JSONTime{}
+		return nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		*T = JSONTime(t)
+		return nil
+	}
+	epoch, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(epoch, 0) || math.IsNaN(epoch) {
+		return fmt.Errorf("invalid time: %s", value)
+	}
+	*T = JSONTime(fromEpoch(epoch))
+	return nil
+}
+
+// MarshalTOML writes a TOML datetime or a number depending on GetTimeFormat,
+// TOML has no null so the zero time is an empty string
+func (T JSONTime) MarshalTOML() ([]byte, error) {
+	if T.IsZero() {
+		return []byte(`""`), nil
+	}
+	return []byte(T.Format(GetTimeFormat())), nil
+}
+
+func (T *JSONTime) UnmarshalTOML(value interface{}) error {
+	switch value := value.(type) {
+	case time.Time:
+		*T = JSONTime(value)
+	case string:
+		return T.UnmarshalText([]byte(value))
+	case int64:
+		*T = JSONTime(fromEpoch(float64(value)))
+	case float64:
+		*T = JSONTime(fromEpoch(value))
+	default:
+		return fmt.Errorf("invalid time: %v", value)
+	}
+	return nil
+}
+
+var (
+	jsonTimeType      = reflect.TypeOf(JSONTime{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// MarshalJSONWithTimeFormat is like json.Marshal but writes the JSONTime
+// values of v in format, whatever the default set by SetTimeFormat is.
+// Object keys are written in sorted order.
+func MarshalJSONWithTimeFormat(v interface{}, format TimeFormat) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return json.Marshal(formatTimes(reflect.ValueOf(v), tree, format))
+}
+
+// formatTimes replaces the JSONTime values of value in tree, the decoded
+// json.Marshal output of value
+func formatTimes(value reflect.Value, tree interface{}, format TimeFormat) interface{} {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return tree
+		}
+		value = value.Elem()
+	}
+	if !value.IsValid() || !value.CanInterface() {
+		return tree
+	}
+
+	if value.Type() == jsonTimeType {
+		T := value.Interface().(JSONTime)
+		if T.IsZero() {
+			return nil
+		}
+		if format == TimeFormatRFC3339Nano {
+			return T.Format(format)
+		}
+		return json.Number(T.Format(format))
+	}
+	if reflect.PointerTo(value.Type()).Implements(jsonMarshalerType) {
+		return tree
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		if object, ok := tree.(map[string]interface{}); ok {
+			formatFields(value, object, format)
+		}
+	case reflect.Slice, reflect.Array:
+		if array, ok := tree.([]interface{}); ok && len(array) == value.Len() {
+			for i := range array {
+				array[i] = formatTimes(value.Index(i), array[i], format)
+			}
+		}
+	case reflect.Map:
+		if object, ok := tree.(map[string]interface{}); ok && value.Type().Key().Kind() == reflect.String {
+			iter := value.MapRange()
+			for iter.Next() {
+				key := iter.Key().String()
+				if element, ok := object[key]; ok {
+					object[key] = formatTimes(iter.Value(), element, format)
+				}
+			}
+		}
+	}
+	return tree
+}
+
+// formatFields handles the exported fields of a struct, fields of embedded
+// structs without a json name are part of the same object
+func formatFields(value reflect.Value, object map[string]interface{}, format TimeFormat) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		if field.Anonymous && len(name) == 0 {
+			embedded := value.Field(i)
+			for embedded.Kind() == reflect.Pointer {
+				if embedded.IsNil() {
+					break
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct && embedded.Type() != jsonTimeType {
+				formatFields(embedded, object, format)
+				continue
+			}
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		if element, ok := object[name]; ok {
+			object[name] = formatTimes(value.Field(i), element, format)
+		}
+	}
+}
+
+func fromEpoch(epoch float64) time.Time {
+	if math.Abs(epoch) >= epochMillisThreshold {
+		return time.UnixMicro(int64(math.Round(epoch * 1e3))).UTC()
+	}
+	return time.UnixMicro(int64(math.Round(epoch * 1e6))).UTC()
+}
@@ -0,0 +1,123 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+var timestamp = time.Date(2024, 3, 4, 5, 6, 7, 890000000, time.UTC)
+
+func TestJSONTimeFormats(t *testing.T) {
+	assert := assert.New(t)
+	t.Cleanup(func() { appliance.SetTimeFormat(appliance.TimeFormatRFC3339Nano) })
+	insight := &appliance.Insight{Name: "a", Timestamp: appliance.JSONTime(timestamp)}
+
+	for format, expected := range map[appliance.TimeFormat]string{
+		appliance.TimeFormatRFC3339Nano:  `"timestamp":"2024-03-04T05:06:07.89Z"`,
+		appliance.TimeFormatEpochMillis:  `"timestamp":1709528767890`,
+		appliance.TimeFormatEpochSeconds: `"timestamp":1709528767`,
+	} {
+		appliance.SetTimeFormat(format)
+		data, err := json.Marshal(insight)
+		assert.NoError(err)
+		assert.Contains(string(data), expected)
+	}
+
+	data, err := json.Marshal(&appliance.Insight{})
+	assert.NoError(err)
+	assert.Contains(string(data), `"timestamp":null`)
+}
+
+func TestJSONTimeParsing(t *testing.T) {
+	assert := assert.New(t)
+	for _, value := range []string{
+		`"2024-03-04T05:06:07.89Z"`,
+		`"2024-03-04T06:06:07.89+01:00"`,
+		`1709528767890`,
+		`"1709528767890"`,
+		`1709528767.89`,
+	} {
+		var parsed appliance.JSONTime
+		assert.NoError(json.Unmarshal([]byte(value), &parsed), value)
+		assert.True(timestamp.Equal(parsed.Time()), value)
+	}
+
+	parsed := appliance.JSONTime(timestamp)
+	assert.NoError(json.Unmarshal([]byte("null"), &parsed))
+	assert.True(parsed.IsZero())
+	assert.Error(json.Unmarshal([]byte(`"yesterday"`), &parsed))
+}
+
+func TestJSONTimeTOML(t *testing.T) {
+	assert := assert.New(t)
+	t.Cleanup(func() { appliance.SetTimeFormat(appliance.TimeFormatRFC3339Nano) })
+	type state struct {
+		Seen  appliance.JSONTime `toml:"seen"`
+		Never appliance.JSONTime `toml:"never"`
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(toml.NewEncoder(&buf).Encode(&state{Seen: appliance.JSONTime(timestamp)}))
+	assert.Equal("seen = 2024-03-04T05:06:07.89Z\nnever = \"\"\n", buf.String())
+
+	decoded := &state{}
+	_, err := toml.Decode(buf.String(), decoded)
+	assert.NoError(err)
+	assert.True(timestamp.Equal(decoded.Seen.Time()))
+	assert.True(decoded.Never.IsZero())
+
+	appliance.SetTimeFormat(appliance.TimeFormatEpochSeconds)
+	buf.Reset()
+	assert.NoError(toml.NewEncoder(&buf).Encode(&state{Seen: appliance.JSONTime(timestamp)}))
+	assert.Contains(buf.String(), "seen = 1709528767\n")
+	_, err = toml.Decode(buf.String(), decoded)
+	assert.NoError(err)
+	assert.Equal(timestamp.Truncate(time.Second), decoded.Seen.Time())
+}
+
+func TestMarshalJSONWithTimeFormat(t *testing.T) {
+	assert := assert.New(t)
+	t.Cleanup(func() { appliance.SetTimeFormat(appliance.TimeFormatRFC3339Nano) })
+	appliance.SetTimeFormat(appliance.TimeFormatEpochSeconds)
+	type embedded struct {
+		Seen appliance.JSONTime `json:"seen"`
+	}
+	value := &struct {
+		embedded
+		Insights []*appliance.Insight          `json:"insights"`
+		ByName   map[string]appliance.JSONTime `json:"byName"`
+		Never    appliance.JSONTime            `json:"never"`
+	}{
+		embedded: embedded{Seen: appliance.JSONTime(timestamp)},
+		Insights: []*appliance.Insight{{Name: "a", Timestamp: appliance.JSONTime(timestamp)}, nil},
+		ByName:   map[string]appliance.JSONTime{"a": appliance.JSONTime(timestamp)},
+	}
+
+	data, err := appliance.MarshalJSONWithTimeFormat(value, appliance.TimeFormatEpochMillis)
+	assert.NoError(err)
+	assert.Contains(string(data), `"seen":1709528767890`)
+	assert.Contains(string(data), `"timestamp":1709528767890`)
+	assert.Contains(string(data), `"byName":{"a":1709528767890}`)
+	assert.Contains(string(data), `"never":null`)
+
+	// the default format does not apply
+	data, err = appliance.MarshalJSONWithTimeFormat(value.Insights, appliance.TimeFormatRFC3339Nano)
+	assert.NoError(err)
+	assert.Contains(string(data), `"timestamp":"2024-03-04T05:06:07.89Z"`)
+	data, err = json.Marshal(value.Insights)
+	assert.NoError(err)
+	assert.Contains(string(data), `"timestamp":1709528767`)
+}
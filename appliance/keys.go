@@ -0,0 +1,172 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/trilogy-group/gfi-agent-sdk/constants"
+	"github.com/trilogy-group/gfi-agent-sdk/utils"
+)
+
+const (
+	// name of the key file created under the agent data dir
+	KeyFileName = "config.key"
+
+	// environment variable holding a base64 encoded 32 byte key
+	KeyEnvName = "GFIAGENT_CONFIG_KEY"
+
+	machineKeySalt = "gfi-agent-sdk/config-key/v1:"
+)
+
+var ErrKeyNotFound = errors.New("encryption key not found")
+
+// KeyProvider supplies the AES-256 key used to encrypt config values
+type KeyProvider interface {
+	Key() (*[32]byte, error)
+}
+
+// DefaultKeyProvider is used by config managers which have no key provider set
+var DefaultKeyProvider KeyProvider = NewFileKeyProvider(filepath.Join(constants.GFIAgentDataDir, KeyFileName))
+
+// FileKeyProvider keeps a random key in a file, generating it on first use
+type FileKeyProvider struct {
+	Path string
+
+	mu  sync.Mutex
+	key *[32]byte
+}
+
+func NewFileKeyProvider(path string) *FileKeyProvider {
+	return &FileKeyProvider{Path: path}
+}
+
+func (F *FileKeyProvider) Key() (*[32]byte, error) {
+	F.mu.Lock()
+	defer F.mu.Unlock()
+
+	if F.key != nil {
+		return F.key, nil
+	}
+
+	key, err := readKeyFile(F.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err = createKeyFile(F.Path)
+		if errors.Is(err, os.ErrExist) {
+			// another process created the key in the meantime
+			key, err = readKeyFile(F.Path)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	F.key = key
+	return key, nil
+}
+
+func readKeyFile(path string) (*[32]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %s", path, err)
+	}
+	return key, nil
+}
+
+func createKeyFile(path string) (*[32]byte, error) {
+	dir := filepath.Dir(path)
+	if !utils.FS.CreateDir(dir) {
+		return nil, fmt.Errorf("could not create dir: %s", dir)
+	}
+
+	key := &[32]byte{}
+	if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.WriteString(base64.StdEncoding.EncodeToString(key[:]) + "\n"); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return key, nil
+}
+
+// EnvKeyProvider reads a base64 encoded key from an environment variable
+type EnvKeyProvider struct {
+	Name string
+}
+
+func NewEnvKeyProvider(name string) *EnvKeyProvider {
+	return &EnvKeyProvider{Name: name}
+}
+
+func (E *EnvKeyProvider) Key() (*[32]byte, error) {
+	value, ok := os.LookupEnv(E.Name)
+	if !ok || len(value) == 0 {
+		return nil, fmt.Errorf("%w: %s is not set", ErrKeyNotFound, E.Name)
+	}
+	key, err := ParseKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key in %s: %s", E.Name, err)
+	}
+	return key, nil
+}
+
+// MachineKeyProvider derives the key from the machine id, so a config copied
+// to another machine cannot be decrypted there
+type MachineKeyProvider struct {
+	MachineId string
+}
+
+func NewMachineKeyProvider(machineId string) *MachineKeyProvider {
+	return &MachineKeyProvider{MachineId: machineId}
+}
+
+func (M *MachineKeyProvider) Key() (*[32]byte, error) {
+	if len(M.MachineId) == 0 {
+		return nil, fmt.Errorf("%w: machine id is empty", ErrKeyNotFound)
+	}
+	key := sha256.Sum256([]byte(machineKeySalt + M.MachineId))
+	return &key, nil
+}
+
+// ParseKey decodes a base64 encoded 32 byte key
+func ParseKey(value string) (*[32]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, errors.New("key is not base64 encoded")
+	}
+	if len(data) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(data))
+	}
+	key := &[32]byte{}
+	copy(key[:], data)
+	return key, nil
+}
@@ -0,0 +1,169 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+// testKeys returns a key provider backed by a key file in a temporary dir
+func testKeys(t *testing.T) appliance.KeyProvider {
+	return appliance.NewFileKeyProvider(filepath.Join(t.TempDir(), appliance.KeyFileName))
+}
+
+func TestFileKeyProvider(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "keys", appliance.KeyFileName)
+
+	key, err := appliance.NewFileKeyProvider(path).Key()
+	assert.NoError(err)
+
+	info, err := os.Stat(path)
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	again, err := appliance.NewFileKeyProvider(path).Key()
+	assert.NoError(err)
+	assert.Equal(key, again)
+}
+
+func TestEnvKeyProvider(t *testing.T) {
+	assert := assert.New(t)
+	provider := appliance.NewEnvKeyProvider("GFIAGENT_TEST_KEY")
+
+	_, err := provider.Key()
+	assert.ErrorIs(err, appliance.ErrKeyNotFound)
+
+	t.Setenv("GFIAGENT_TEST_KEY", base64.StdEncoding.EncodeToString(make([]byte, 16)))
+	_, err = provider.Key()
+	assert.Error(err)
+
+	raw := make([]byte, 32)
+	raw[0] = 1
+	t.Setenv("GFIAGENT_TEST_KEY", base64.StdEncoding.EncodeToString(raw))
+	key, err := provider.Key()
+	assert.NoError(err)
+	assert.Equal(byte(1), key[0])
+}
+
+func TestMachineKeyProvider(t *testing.T) {
+	assert := assert.New(t)
+
+	a, err := appliance.NewMachineKeyProvider("machine-a").Key()
+	assert.NoError(err)
+	b, err := appliance.NewMachineKeyProvider("machine-b").Key()
+	assert.NoError(err)
+	assert.NotEqual(a, b)
+
+	_, err = appliance.NewMachineKeyProvider("").Key()
+	assert.ErrorIs(err, appliance.ErrKeyNotFound)
+}
+
+func TestConfigManagerUsesKeyProvider(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	keys := appliance.NewMachineKeyProvider("machine-a")
+
+	cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, dir)
+	cfgMgr.Keys = keys
+	assert.NoError(cfgMgr.SaveApplianceConfig(&appliance.Config{Id: "1", Password: "secret"}))
+
+	loaded := &appliance.Config{}
+	assert.NoError(cfgMgr.LoadApplianceConfig(loaded))
+	assert.Equal("secret", loaded.Password)
+
+	other := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, dir)
+	other.Keys = appliance.NewMachineKeyProvider("machine-b")
+	assert.Error(other.LoadApplianceConfig(&appliance.Config{}))
+}
+
+func TestEnvelopeRotation(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	oldKeys := appliance.NewMachineKeyProvider("old")
+	newKeys := appliance.NewMachineKeyProvider("new")
+	oldKey, _ := oldKeys.Key()
+	newKey, _ := newKeys.Key()
+
+	cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, dir)
+	cfgMgr.Keys = oldKeys
+	assert.NoError(cfgMgr.SaveApplianceConfig(&appliance.Config{Id: "1", Password: "secret"}))
+
+	cfgMgr.Keys = appliance.NewRotatingKeyProvider(newKeys, oldKeys)
+	loaded := &appliance.Config{}
+	assert.NoError(cfgMgr.LoadApplianceConfig(loaded))
+	assert.Equal("secret", loaded.Password)
+
+	stored := &appliance.Config{}
+	assert.NoError(cfgMgr.Unmarshal(stored))
+	envelope, err := appliance.ParseEnvelope(stored.PasswordEncrypted)
+	assert.NoError(err)
+	assert.Equal(appliance.KeyId(newKey), envelope.KeyId)
+	assert.NotEqual(appliance.KeyId(oldKey), envelope.KeyId)
+}
+
+func TestOpenEnvelopeLegacyFormat(t *testing.T) {
+	assert := assert.New(t)
+	keys := appliance.NewMachineKeyProvider("machine-a")
+	key, _ := keys.Key()
+
+	encrypted, err := appliance.Encrypt([]byte("secret"), key)
+	assert.NoError(err)
+
+	plaintext, stale, err := appliance.OpenEnvelope(appliance.Base64Encode(string(encrypted)), keys)
+	assert.NoError(err)
+	assert.True(stale)
+	assert.Equal("secret", plaintext)
+
+	sealed, err := appliance.SealEnvelope("secret", keys)
+	assert.NoError(err)
+	plaintext, stale, err = appliance.OpenEnvelope(sealed, keys)
+	assert.NoError(err)
+	assert.False(stale)
+	assert.Equal("secret", plaintext)
+
+	_, _, err = appliance.OpenEnvelope(sealed, appliance.NewMachineKeyProvider("machine-b"))
+	assert.ErrorIs(err, appliance.ErrUnknownKey)
+}
+
+func TestLoadWithoutCurrentKey(t *testing.T) {
+	assert := assert.New(t)
+	oldKeys := appliance.NewMachineKeyProvider("old")
+	cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, t.TempDir())
+	cfgMgr.Keys = oldKeys
+	assert.NoError(cfgMgr.SaveApplianceConfig(&appliance.Config{Id: "1", Password: "secret", PrivateKey: "private"}))
+	saved, err := os.ReadFile(cfgMgr.FullPath())
+	assert.NoError(err)
+
+	// the current key and one of the retired keys are not available
+	keys := appliance.NewRotatingKeyProvider(
+		appliance.NewEnvKeyProvider("GFIAGENT_TEST_MISSING_KEY"),
+		appliance.NewEnvKeyProvider("GFIAGENT_TEST_MISSING_RETIRED_KEY"),
+		oldKeys,
+	)
+	retired, err := keys.RetiredKeys()
+	assert.ErrorIs(err, appliance.ErrKeyNotFound)
+	assert.Len(retired, 1)
+
+	cfgMgr.Keys = keys
+	loaded := &appliance.Config{}
+	assert.NoError(cfgMgr.LoadApplianceConfig(loaded))
+	assert.Equal("secret", loaded.Password)
+	assert.Equal("private", loaded.PrivateKey)
+
+	// re-encrypting failed, so the config is left as it was
+	data, err := os.ReadFile(cfgMgr.FullPath())
+	assert.NoError(err)
+	assert.Equal(saved, data)
+}
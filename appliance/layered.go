@@ -0,0 +1,329 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// layers of a config loaded by LoadLayered, later layers override earlier ones
+const (
+	LayerDefault     = "default"
+	LayerSystem      = "system"
+	LayerAppliance   = "appliance"
+	LayerEnvironment = "environment"
+)
+
+// prefix of environment variables overriding config values, serverDir is
+// overridden by GFIAGENT_SERVER_DIR
+const EnvPrefix = "GFIAGENT_"
+
+const redactedValue = "<redacted>"
+
+// LayeredConfig is the effective config built by LoadLayered along with the
+// layer each value came from
+type LayeredConfig struct {
+	Config  interface{}
+	Sources map[string]string
+
+	fields []layeredField
+}
+
+// LayeredValue is a single effective value, secrets are redacted
+type LayeredValue struct {
+	Key    string
+	Value  interface{}
+	Source string
+}
+
+type layeredField struct {
+	index  int
+	name   string
+	key    string
+	env    string
+	redact bool
+}
+
+// LoadLayered loads config from the built-in defaults it holds on entry, then
+// the config file of the same name in systemDir, then the config file of the
+// manager and finally from GFIAGENT_* environment variables. Missing files
+// are skipped, an empty systemDir skips the system layer. Secrets and the
+// password of ConfigWithPassword are decrypted. Files are migrated in memory
+// like Reload does, they are neither rewritten nor re-encrypted.
+func (C *ConfigManager) LoadLayered(config interface{}, systemDir string) (*LayeredConfig, error) {
+	rv := reflect.ValueOf(config)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("layered config must be a pointer to a struct")
+	}
+
+	layered := &LayeredConfig{
+		Config:  config,
+		Sources: map[string]string{},
+		fields:  layeredFields(rv.Elem().Type(), C.Type),
+	}
+	for _, field := range layered.fields {
+		layered.Sources[field.key] = LayerDefault
+	}
+
+	if len(systemDir) > 0 && systemDir != C.Path {
+		system := NewConfigManager(C.Name, C.Type, systemDir)
+		system.Keys = C.Keys
+		system.LockTimeout = C.LockTimeout
+		system.Migrations = C.Migrations
+		if err := layered.applyFile(system, LayerSystem); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := layered.applyFile(C, LayerAppliance); err != nil {
+		return nil, err
+	}
+
+	if err := layered.applyEnv(rv.Elem()); err != nil {
+		return nil, err
+	}
+	return layered, nil
+}
+
+func (L *LayeredConfig) applyFile(cfgMgr *ConfigManager, layer string) error {
+	codec, err := LookupCodec(cfgMgr.Type)
+	if err != nil {
+		return err
+	}
+	data, err := cfgMgr.readFile(cfgMgr.FullPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	content := map[string]interface{}{}
+	if err := codec.Unmarshal(data, &content); err != nil {
+		return err
+	}
+	if cfgMgr.Migrations != nil {
+		migrated, err := cfgMgr.Migrations.Migrate(content)
+		if err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", cfgMgr.FullPath(), err)
+		}
+		if migrated {
+			if data, err = codec.Marshal(content); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the password of an earlier layer is kept unless this one has its own
+	withPassword, hasPassword := L.Config.(ConfigWithPassword)
+	password, encrypted := "", ""
+	if hasPassword {
+		password, encrypted = withPassword.GetPassword(), withPassword.GetPasswordEncrypted()
+		withPassword.SetPassword("")
+		withPassword.SetPasswordEncrypted("")
+	}
+
+	if err := codec.Unmarshal(data, L.Config); err != nil {
+		return err
+	}
+	if _, err := openSecrets(L.Config, cfgMgr.keyProvider()); err != nil {
+		return err
+	}
+	if hasPassword {
+		if len(withPassword.GetPassword()) == 0 && len(withPassword.GetPasswordEncrypted()) == 0 {
+			withPassword.SetPassword(password)
+			withPassword.SetPasswordEncrypted(encrypted)
+		} else if _, err := openPassword(L.Config, cfgMgr.keyProvider()); err != nil {
+			return fmt.Errorf("failed to decrypt password of %s: %w", cfgMgr.FullPath(), err)
+		} else {
+			L.setSource(passwordField, layer)
+		}
+	}
+
+	for key := range content {
+		for _, field := range L.fields {
+			if strings.EqualFold(key, field.key) {
+				L.Sources[field.key] = layer
+			}
+		}
+	}
+	return nil
+}
+
+// setSource records layer as source of the field named name
+func (L *LayeredConfig) setSource(name string, layer string) {
+	for _, field := range L.fields {
+		if field.name == name {
+			L.Sources[field.key] = layer
+		}
+	}
+}
+
+func (L *LayeredConfig) applyEnv(rv reflect.Value) error {
+	for _, field := range L.fields {
+		value, ok := os.LookupEnv(field.env)
+		if !ok {
+			continue
+		}
+		if err := setFromString(rv.Field(field.index), value); err != nil {
+			return fmt.Errorf("invalid value of %s: %s", field.env, err)
+		}
+		L.Sources[field.key] = LayerEnvironment
+	}
+	return nil
+}
+
+// Source returns the layer the value of key came from
+func (L *LayeredConfig) Source(key string) string {
+	return L.Sources[key]
+}
+
+// Values returns the effective values in field order with secrets redacted
+func (L *LayeredConfig) Values() []LayeredValue {
+	rv := reflect.ValueOf(L.Config).Elem()
+	values := []LayeredValue{}
+	for _, field := range L.fields {
+		var value interface{} = redactedValue
+		if !field.redact {
+			value = displayValue(rv.Field(field.index))
+		}
+		values = append(values, LayeredValue{Key: field.key, Value: value, Source: L.Sources[field.key]})
+	}
+	return values
+}
+
+// Dump formats the effective config for logs and support, secrets are redacted
+func (L *LayeredConfig) Dump() string {
+	var sb strings.Builder
+	for _, value := range L.Values() {
+		sb.WriteString(fmt.Sprintf("%s = %v (%s)\n", value.Key, value.Value, value.Source))
+	}
+	return sb.String()
+}
+
+func displayValue(rv reflect.Value) interface{} {
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if stringer, ok := rv.Interface().(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return rv.Interface()
+}
+
+func layeredFields(rt reflect.Type, configType string) []layeredField {
+	fields := []layeredField{}
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		key := fieldKey(field, configType)
+		if key == "-" {
+			continue
+		}
+		fields = append(fields, layeredField{
+			index:  i,
+			name:   field.Name,
+			key:    key,
+			env:    EnvPrefix + envName(key),
+			redact: isRedacted(field),
+		})
+	}
+	return fields
+}
+
+// fieldKey returns the key of field in config files of configType
+func fieldKey(field reflect.StructField, configType string) string {
+	tagName := strings.ToLower(configType)
+	if tagName == "yml" {
+		tagName = "yaml"
+	}
+	name := strings.Split(field.Tag.Get(tagName), ",")[0]
+	if len(name) > 0 {
+		return name
+	}
+	if tagName == "yaml" {
+		return strings.ToLower(field.Name)
+	}
+	return field.Name
+}
+
+// envName converts a config key like serverDir to SERVER_DIR
+func envName(key string) string {
+	var sb strings.Builder
+	runes := []rune(key)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			sb.WriteRune('_')
+		}
+		if r == '-' || r == '.' {
+			r = '_'
+		}
+		sb.WriteRune(unicode.ToUpper(r))
+	}
+	return sb.String()
+}
+
+// setFromString parses value into rv, which is a field of an addressable struct
+func setFromString(rv reflect.Value, value string) error {
+	if rv.Kind() == reflect.Ptr {
+		ptr := reflect.New(rv.Type().Elem())
+		if err := setFromString(ptr.Elem(), value); err != nil {
+			return err
+		}
+		rv.Set(ptr)
+		return nil
+	}
+
+	if unmarshaler, ok := rv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", rv.Type())
+	}
+	return nil
+}
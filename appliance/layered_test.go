@@ -0,0 +1,99 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+func TestLoadLayered(t *testing.T) {
+	assert := assert.New(t)
+	systemDir := t.TempDir()
+	applianceDir := t.TempDir()
+	assert.NoError(os.WriteFile(filepath.Join(systemDir, "config.toml"), []byte("serverDir = \"/opt/system\"\nusername = \"admin\"\n"), 0644))
+	assert.NoError(os.WriteFile(filepath.Join(applianceDir, "config.toml"), []byte("id = \"1\"\nusername = \"agent\"\n"), 0644))
+	t.Setenv("GFIAGENT_SERVER_DIR", "/opt/override")
+
+	cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, applianceDir)
+	cfgMgr.Keys = appliance.NewMachineKeyProvider("machine-a")
+	config := &appliance.Config{Type: "Kerio-Connect", Password: "secret"}
+
+	layered, err := cfgMgr.LoadLayered(config, systemDir)
+	assert.NoError(err)
+	assert.Equal("/opt/override", config.ServerDir)
+	assert.Equal("agent", config.Username)
+	assert.Equal("1", config.Id)
+
+	assert.Equal(appliance.LayerEnvironment, layered.Source("serverDir"))
+	assert.Equal(appliance.LayerAppliance, layered.Source("username"))
+	assert.Equal(appliance.LayerAppliance, layered.Source("id"))
+	assert.Equal(appliance.LayerDefault, layered.Source("type"))
+
+	dump := layered.Dump()
+	assert.Contains(dump, "serverDir = /opt/override (environment)\n")
+	assert.Contains(dump, "password = <redacted> (default)\n")
+	assert.NotContains(dump, "secret")
+}
+
+func TestLoadLayeredEnvironmentTypes(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv("GFIAGENT_ENABLE_UPDATE", "false")
+	t.Setenv("GFIAGENT_ENABLE_SENTRY", "true")
+
+	cfgMgr := appliance.NewConfigManager(appliance.CommonConfigName, appliance.CommonConfigType, t.TempDir())
+	config := &appliance.CommonConfig{}
+	layered, err := cfgMgr.LoadLayered(config, "")
+	assert.NoError(err)
+	assert.NotNil(config.EnableUpdate)
+	assert.False(*config.EnableUpdate)
+	assert.True(config.EnableSentry)
+	assert.Equal(appliance.LayerEnvironment, layered.Source("enableUpdate"))
+
+	t.Setenv("GFIAGENT_ENABLE_SENTRY", "maybe")
+	_, err = cfgMgr.LoadLayered(&appliance.CommonConfig{}, "")
+	assert.ErrorContains(err, "GFIAGENT_ENABLE_SENTRY")
+}
+
+func TestLoadLayeredMigratesWithoutWriting(t *testing.T) {
+	assert := assert.New(t)
+	applianceDir := t.TempDir()
+	keys := appliance.NewMachineKeyProvider("machine-a")
+	assert.NoError((&appliance.Config{Id: "1", Type: "Kerio-Connect", Password: "secret", PrivateKey: "private", Keys: keys}).Save(applianceDir))
+
+	migrations := appliance.NewMigrations()
+	assert.NoError(migrations.RegisterMigration(0, 1, func(config map[string]interface{}) error {
+		config["username"] = "migrated"
+		return nil
+	}))
+	cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, applianceDir)
+	cfgMgr.Keys = keys
+	cfgMgr.Migrations = migrations
+	before, err := os.ReadFile(cfgMgr.FullPath())
+	assert.NoError(err)
+
+	config := &appliance.Config{Password: "default"}
+	layered, err := cfgMgr.LoadLayered(config, "")
+	assert.NoError(err)
+	assert.Equal("secret", config.Password)
+	assert.Equal("private", config.PrivateKey)
+	assert.Equal("migrated", config.Username)
+	assert.Equal(1, config.SchemaVersion)
+	assert.Equal(appliance.LayerAppliance, layered.Source("username"))
+	assert.Equal(appliance.LayerAppliance, layered.Source("password"))
+	assert.NotContains(layered.Dump(), "secret")
+
+	after, err := os.ReadFile(cfgMgr.FullPath())
+	assert.NoError(err)
+	assert.Equal(before, after)
+	assert.False(fileExists(cfgMgr.BackupPath()))
+}
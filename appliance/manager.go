@@ -0,0 +1,202 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/trilogy-group/gfi-agent-sdk/utils"
+)
+
+var (
+	ErrApplianceExists   = errors.New("appliance already exists")
+	ErrApplianceNotFound = errors.New("appliance not found")
+)
+
+// ApplianceFactory creates the appliance a config belongs to
+type ApplianceFactory func(cfg *Config) (Appliance, error)
+
+// Manager keeps the appliances hosted by the agent, keyed by their id. It is
+// safe for concurrent use.
+type Manager struct {
+	// dir holding one sub dir with a config per appliance
+	Dir     string
+	Factory ApplianceFactory
+
+	// key provider of the appliance configs, DefaultKeyProvider when nil
+	Keys KeyProvider
+
+	mu         sync.RWMutex
+	appliances map[string]Appliance
+}
+
+func NewManager(dir string, factory ApplianceFactory) *Manager {
+	return &Manager{
+		Dir:        dir,
+		Factory:    factory,
+		appliances: map[string]Appliance{},
+	}
+}
+
+// Load creates appliances for the configs found in sub dirs of Dir. Appliances
+// managed before the call are kept, configs of one id found in several dirs
+// are reported as ErrApplianceExists. Failures are collected and returned
+// together.
+func (M *Manager) Load() error {
+	dirs, err := utils.FS.ListDir(M.Dir)
+	if err != nil {
+		return err
+	}
+	sort.Strings(dirs)
+
+	errs := []error{}
+	scanned := map[string]string{}
+	for _, name := range dirs {
+		dir := filepath.Join(M.Dir, name)
+		if !utils.FS.FileExists(filepath.Join(dir, ApplianceConfigName+"."+ApplianceConfigType)) {
+			continue
+		}
+
+		cfg := &Config{Keys: M.Keys}
+		if err := cfg.Reload(dir); err != nil {
+			errs = append(errs, fmt.Errorf("failed to load config from %s: %w", dir, err))
+			continue
+		}
+		cfg.Dir = dir
+
+		if first, ok := scanned[cfg.Id]; ok {
+			errs = append(errs, fmt.Errorf("%w: %s in %s and %s", ErrApplianceExists, cfg.Id, first, dir))
+			continue
+		}
+		scanned[cfg.Id] = dir
+		if _, ok := M.Get(cfg.Id); ok {
+			continue
+		}
+
+		a, err := M.Factory(cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to create appliance from %s: %w", dir, err))
+			continue
+		}
+		if err := M.Add(a); err != nil && !errors.Is(err, ErrApplianceExists) {
+			errs = append(errs, fmt.Errorf("failed to add appliance from %s: %w", dir, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (M *Manager) Add(a Appliance) error {
+	id := a.Id()
+	if len(id) == 0 {
+		return errors.New("appliance id is empty")
+	}
+
+	M.mu.Lock()
+	defer M.mu.Unlock()
+	if _, ok := M.appliances[id]; ok {
+		return fmt.Errorf("%w: %s", ErrApplianceExists, id)
+	}
+	M.appliances[id] = a
+	return nil
+}
+
+// Remove stops managing the appliance, its config is left untouched
+func (M *Manager) Remove(id string) error {
+	M.mu.Lock()
+	defer M.mu.Unlock()
+	if _, ok := M.appliances[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrApplianceNotFound, id)
+	}
+	delete(M.appliances, id)
+	return nil
+}
+
+func (M *Manager) Get(id string) (Appliance, bool) {
+	M.mu.RLock()
+	defer M.mu.RUnlock()
+	a, ok := M.appliances[id]
+	return a, ok
+}
+
+// Ids returns ids of the managed appliances in sorted order
+func (M *Manager) Ids() []string {
+	M.mu.RLock()
+	defer M.mu.RUnlock()
+	ids := make([]string, 0, len(M.appliances))
+	for id := range M.appliances {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (M *Manager) Len() int {
+	M.mu.RLock()
+	defer M.mu.RUnlock()
+	return len(M.appliances)
+}
+
+// Snapshot returns clones of the managed appliances sorted by id, callers
+// can use them without affecting the managed instances
+func (M *Manager) Snapshot() []Appliance {
+	snapshot := []Appliance{}
+	for _, a := range M.list() {
+		snapshot = append(snapshot, a.Clone())
+	}
+	return snapshot
+}
+
+func (M *Manager) SaveConfigs() error {
+	return M.each(func(a Appliance) error { return a.SaveConfigs() })
+}
+
+func (M *Manager) ReloadConfigs() error {
+	return M.each(func(a Appliance) error { return a.ReloadConfigs() })
+}
+
+func (M *Manager) CheckUpdate() error {
+	return M.each(func(a Appliance) error { return a.CheckUpdate() })
+}
+
+// list returns the managed appliances sorted by id
+func (M *Manager) list() []Appliance {
+	ids := M.Ids()
+	M.mu.RLock()
+	defer M.mu.RUnlock()
+	appliances := make([]Appliance, 0, len(ids))
+	for _, id := range ids {
+		if a, ok := M.appliances[id]; ok {
+			appliances = append(appliances, a)
+		}
+	}
+	return appliances
+}
+
+// each runs fn for all appliances concurrently and joins the errors, each of
+// them prefixed by the id of the failing appliance
+func (M *Manager) each(fn func(a Appliance) error) error {
+	appliances := M.list()
+	errs := make([]error, len(appliances))
+
+	var wg sync.WaitGroup
+	for i, a := range appliances {
+		wg.Add(1)
+		go func(i int, a Appliance) {
+			defer wg.Done()
+			if err := fn(a); err != nil {
+				errs[i] = fmt.Errorf("appliance %s: %w", a.Id(), err)
+			}
+		}(i, a)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
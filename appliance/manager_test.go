@@ -0,0 +1,156 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+// stubAppliance implements the methods of appliance.Appliance used by
+// Manager, calling any other method panics
+type stubAppliance struct {
+	appliance.Appliance
+
+	cfg       appliance.Config
+	err       error
+	checks    int
+	noPersist bool
+}
+
+func newStub(cfg *appliance.Config) (appliance.Appliance, error) {
+	return &stubAppliance{cfg: *cfg}, nil
+}
+
+func (S *stubAppliance) Id() string {
+	return S.cfg.Id
+}
+
+func (S *stubAppliance) Dir() string {
+	return S.cfg.Dir
+}
+
+func (S *stubAppliance) SerialNumber() string {
+	return S.cfg.SerialNumber
+}
+
+func (S *stubAppliance) UpdateSerialNumber(serialNumber string) error {
+	S.cfg.SerialNumber = serialNumber
+	return nil
+}
+
+func (S *stubAppliance) SaveConfigs() error {
+	if S.noPersist {
+		return nil
+	}
+	return S.cfg.Save(S.cfg.Dir)
+}
+
+func (S *stubAppliance) ReloadConfigs() error {
+	return S.cfg.Reload(S.cfg.Dir)
+}
+
+func (S *stubAppliance) CheckUpdate() error {
+	S.checks++
+	return S.err
+}
+
+func (S *stubAppliance) Clone() appliance.Appliance {
+	clone := *S
+	clone.noPersist = true
+	return &clone
+}
+
+func TestManager(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	keys := testKeys(t)
+	for _, id := range []string{"b", "a"} {
+		assert.NoError((&appliance.Config{Id: id, Keys: keys}).Save(filepath.Join(dir, id)))
+	}
+	assert.NoError(os.Mkdir(filepath.Join(dir, "empty"), 0755))
+
+	manager := appliance.NewManager(dir, newStub)
+	manager.Keys = keys
+	assert.NoError(manager.Load())
+	assert.NoError(manager.Load())
+	assert.Equal([]string{"a", "b"}, manager.Ids())
+
+	a, ok := manager.Get("a")
+	assert.True(ok)
+	assert.Equal(filepath.Join(dir, "a"), a.Dir())
+	assert.ErrorIs(manager.Add(a), appliance.ErrApplianceExists)
+	assert.Error(manager.Add(&stubAppliance{}))
+
+	snapshot := manager.Snapshot()
+	assert.Len(snapshot, 2)
+	assert.Equal(a.Dir(), snapshot[0].Dir())
+	assert.NoError(snapshot[0].UpdateSerialNumber("SN-1"))
+	assert.NoError(snapshot[0].SaveConfigs())
+	assert.Empty(a.SerialNumber())
+	saved := &appliance.Config{Keys: keys}
+	assert.NoError(saved.Reload(filepath.Join(dir, "a")))
+	assert.Empty(saved.SerialNumber)
+
+	assert.NoError(a.UpdateSerialNumber("SN-2"))
+	assert.NoError(manager.SaveConfigs())
+	assert.NoError(saved.Reload(filepath.Join(dir, "a")))
+	assert.Equal("SN-2", saved.SerialNumber)
+
+	a.(*stubAppliance).err = errors.New("update failed")
+	err := manager.CheckUpdate()
+	assert.ErrorContains(err, "appliance a: update failed")
+	assert.NotContains(err.Error(), "appliance b")
+	assert.Equal(1, a.(*stubAppliance).checks)
+
+	assert.NoError(manager.Remove("a"))
+	assert.ErrorIs(manager.Remove("a"), appliance.ErrApplianceNotFound)
+	assert.Equal(1, manager.Len())
+}
+
+func TestManagerLoadErrors(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	keys := testKeys(t)
+	assert.NoError((&appliance.Config{Id: "a", Keys: keys}).Save(filepath.Join(dir, "a")))
+	assert.NoError(os.Mkdir(filepath.Join(dir, "broken"), 0755))
+	assert.NoError(os.WriteFile(filepath.Join(dir, "broken", "config.toml"), []byte("id = "), 0644))
+
+	manager := appliance.NewManager(dir, newStub)
+	manager.Keys = keys
+	err := manager.Load()
+	assert.ErrorContains(err, filepath.Join(dir, "broken"))
+	assert.Equal([]string{"a"}, manager.Ids())
+}
+
+func TestManagerLoadDuplicateIds(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	keys := testKeys(t)
+	for _, name := range []string{"a", "copy"} {
+		assert.NoError((&appliance.Config{Id: "a", Keys: keys}).Save(filepath.Join(dir, name)))
+	}
+
+	manager := appliance.NewManager(dir, newStub)
+	manager.Keys = keys
+	err := manager.Load()
+	assert.ErrorIs(err, appliance.ErrApplianceExists)
+	assert.ErrorContains(err, filepath.Join(dir, "a")+" and "+filepath.Join(dir, "copy"))
+	a, ok := manager.Get("a")
+	assert.True(ok)
+	assert.Equal(filepath.Join(dir, "a"), a.Dir())
+
+	// reported again on reload, the managed appliance is kept
+	assert.ErrorIs(manager.Load(), appliance.ErrApplianceExists)
+	assert.Equal(1, manager.Len())
+}
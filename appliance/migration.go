@@ -0,0 +1,126 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/trilogy-group/gfi-agent-sdk/logger"
+)
+
+// key holding the schema version in config files, a missing key is version 0
+const SchemaVersionKey = "schemaVersion"
+
+// MigrationFunc upgrades the raw content of a config file in place
+type MigrationFunc func(config map[string]interface{}) error
+
+// ConfigWithSchemaVersion is implemented by configs which store the schema
+// version they were written with, so Save can stamp the latest version
+type ConfigWithSchemaVersion interface {
+	GetSchemaVersion() int
+	SetSchemaVersion(version int)
+}
+
+// Migrations is a registry of schema migrations for one kind of config file
+type Migrations struct {
+	mu    sync.RWMutex
+	steps map[int]migration
+}
+
+type migration struct {
+	to      int
+	migrate MigrationFunc
+}
+
+// migrations of appliance and common config files, run by Config.Reload and CommonConfig.Reload
+var ApplianceMigrations = NewMigrations()
+var CommonMigrations = NewMigrations()
+
+func NewMigrations() *Migrations {
+	return &Migrations{steps: map[int]migration{}}
+}
+
+// RegisterMigration registers migrate to upgrade configs from one schema
+// version to a later one. Only one migration can start at each version.
+func (M *Migrations) RegisterMigration(from int, to int, migrate MigrationFunc) error {
+	if to <= from {
+		return fmt.Errorf("migration must upgrade the schema version, got %d -> %d", from, to)
+	}
+
+	M.mu.Lock()
+	defer M.mu.Unlock()
+	if existing, ok := M.steps[from]; ok {
+		return fmt.Errorf("migration from schema version %d to %d is already registered", from, existing.to)
+	}
+	M.steps[from] = migration{to: to, migrate: migrate}
+	return nil
+}
+
+// Latest returns the schema version configs are migrated to
+func (M *Migrations) Latest() int {
+	M.mu.RLock()
+	defer M.mu.RUnlock()
+	latest := 0
+	for _, step := range M.steps {
+		if step.to > latest {
+			latest = step.to
+		}
+	}
+	return latest
+}
+
+// Migrate runs the migrations needed to bring config to the latest schema
+// version and returns true when config was changed
+func (M *Migrations) Migrate(config map[string]interface{}) (bool, error) {
+	version, err := schemaVersionOf(config)
+	if err != nil {
+		return false, err
+	}
+
+	latest := M.Latest()
+	if version > latest {
+		logger.Logger.Warningf("Config schema version %d is newer than the supported version %d", version, latest)
+		return false, nil
+	}
+
+	M.mu.RLock()
+	defer M.mu.RUnlock()
+	migrated := false
+	for version < latest {
+		step, ok := M.steps[version]
+		if !ok {
+			return migrated, fmt.Errorf("no migration from config schema version %d", version)
+		}
+		if err := step.migrate(config); err != nil {
+			return migrated, fmt.Errorf("failed to migrate config from schema version %d to %d: %s", version, step.to, err)
+		}
+		version = step.to
+		config[SchemaVersionKey] = version
+		migrated = true
+	}
+	return migrated, nil
+}
+
+// schemaVersionOf reads the schema version, codecs decode numbers to different types
+func schemaVersionOf(config map[string]interface{}) (int, error) {
+	switch version := config[SchemaVersionKey].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return version, nil
+	case int64:
+		return int(version), nil
+	case uint64:
+		return int(version), nil
+	case float64:
+		return int(version), nil
+	default:
+		return 0, fmt.Errorf("invalid config schema version: %v", version)
+	}
+}
@@ -0,0 +1,86 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+func TestMigrations(t *testing.T) {
+	assert := assert.New(t)
+	migrations := appliance.NewMigrations()
+	assert.NoError(migrations.RegisterMigration(0, 1, func(config map[string]interface{}) error {
+		config["serverDir"] = config["installDir"]
+		delete(config, "installDir")
+		return nil
+	}))
+	assert.NoError(migrations.RegisterMigration(1, 2, func(config map[string]interface{}) error {
+		config["signupStatus"] = true
+		return nil
+	}))
+	assert.Error(migrations.RegisterMigration(1, 3, func(config map[string]interface{}) error { return nil }))
+	assert.Error(migrations.RegisterMigration(3, 3, func(config map[string]interface{}) error { return nil }))
+	assert.Equal(2, migrations.Latest())
+
+	cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, t.TempDir())
+	cfgMgr.Migrations = migrations
+	assert.NoError(os.WriteFile(cfgMgr.FullPath(), []byte("id = \"1\"\ninstallDir = \"/opt/kerio\"\n"), 0644))
+
+	loaded := &appliance.Config{}
+	assert.NoError(cfgMgr.Unmarshal(loaded))
+	assert.Equal(2, loaded.SchemaVersion)
+	assert.Equal("/opt/kerio", loaded.ServerDir)
+	assert.True(loaded.SignUpStatus)
+
+	// the migrated config is persisted and the original kept as backup
+	stored := map[string]interface{}{}
+	assert.NoError(appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, cfgMgr.Path).Unmarshal(&stored))
+	assert.Equal(int64(2), stored[appliance.SchemaVersionKey])
+	backup, err := os.ReadFile(cfgMgr.BackupPath())
+	assert.NoError(err)
+	assert.Contains(string(backup), "installDir")
+
+	// configs written by current code are stamped with the latest version
+	assert.NoError(cfgMgr.Save(&appliance.Config{Id: "2"}))
+	saved := map[string]interface{}{}
+	assert.NoError(appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, cfgMgr.Path).Unmarshal(&saved))
+	assert.Equal(int64(2), saved[appliance.SchemaVersionKey])
+}
+
+func TestSchemaVersionRoundTrip(t *testing.T) {
+	for _, configType := range []string{"toml", "json", "yaml"} {
+		t.Run(configType, func(t *testing.T) {
+			assert := assert.New(t)
+			runs := 0
+			migrations := appliance.NewMigrations()
+			assert.NoError(migrations.RegisterMigration(0, 1, func(config map[string]interface{}) error {
+				runs++
+				return nil
+			}))
+
+			cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, configType, t.TempDir())
+			cfgMgr.Keys = appliance.NewMachineKeyProvider("machine-a")
+			cfgMgr.Migrations = migrations
+			assert.NoError(cfgMgr.Save(&appliance.Config{Id: "1"}))
+
+			loaded := &appliance.Config{}
+			assert.NoError(cfgMgr.LoadApplianceConfig(loaded))
+			assert.Equal(1, loaded.SchemaVersion)
+			assert.Equal(0, runs)
+
+			data, err := os.ReadFile(cfgMgr.FullPath())
+			assert.NoError(err)
+			assert.Equal(1, strings.Count(strings.ToLower(string(data)), "schemaversion"))
+		})
+	}
+}
@@ -18,10 +18,10 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"sync"
+	"reflect"
 	"time"
 
-	"github.com/BurntSushi/toml"
+	"github.com/trilogy-group/gfi-agent-sdk/logger"
 	"github.com/trilogy-group/gfi-agent-sdk/utils"
 )
 
@@ -33,6 +33,8 @@ const (
 	NotRegistered Status = iota
 	Registering
 	Registered
+	RegistrationFailed
+	Deregistering
 )
 
 type Appliance interface {
@@ -165,7 +167,7 @@ type Insight struct {
 type Notification struct {
 	Type        string       `json:"type"`
 	Name        string       `json:"name"`
-	Severity    string       `json:"severity"`
+	Severity    Severity     `json:"severity"`
 	Message     string       `json:"message"`
 	Dimensions  []*Dimension `json:"dimensions"`
 	Timestamp   JSONTime     `json:"timestamp"`
@@ -173,42 +175,72 @@ type Notification struct {
 }
 
 type Config struct {
+	// version of the config schema, see ApplianceMigrations
+	SchemaVersion int `toml:"schemaVersion" json:"schemaVersion" yaml:"schemaVersion"`
+
 	// unique identifier for the appliance
-	Id string `toml:"id"`
+	Id string `toml:"id" json:"id" yaml:"id"`
 
 	// type of the appliance
-	Type string `toml:"type"`
+	Type string `toml:"type" json:"type" yaml:"type"`
 
 	// path to the installation dir for the appliance
-	ServerDir string `toml:"serverDir"`
+	ServerDir string `toml:"serverDir" json:"serverDir" yaml:"serverDir"`
 
 	// private and public key for making calls to the app manager backend
-	PrivateKey string `toml:"privateKey"`
-	PublicKey  string `toml:"publicKey"`
+	PrivateKey string `toml:"privateKey" json:"privateKey" yaml:"privateKey" secret:"true"`
+	PublicKey  string `toml:"publicKey" json:"publicKey" yaml:"publicKey"`
 
 	// username and password to authenticate with the appliance
-	Username          string `toml:"username"`
-	Password          string `toml:"password"`
-	PasswordEncrypted string `toml:"passwordEncrypted"`
+	Username          string `toml:"username" json:"username" yaml:"username"`
+	Password          string `toml:"password" json:"password,omitempty" yaml:"password,omitempty" secret:"redact"`
+	PasswordEncrypted string `toml:"passwordEncrypted" json:"passwordEncrypted" yaml:"passwordEncrypted" secret:"redact"`
 
 	// true if agent has signed up with the appliance
-	SignUpStatus bool `toml:"signupStatus"`
+	SignUpStatus bool `toml:"signupStatus" json:"signupStatus" yaml:"signupStatus"`
+
+	// current status of registration [NOT_REGISTERED, REGISTERING, REGISTERED, REGISTRATION_FAILED, DEREGISTERING]
+	RegistrationStatus Status `toml:"registrationStatus" json:"registrationStatus" yaml:"registrationStatus"`
 
-	// current status of registration [REGISTERED, NOT_REGISTERED, REGISTERING]
-	RegistrationStatus Status `toml:"registrationStatus"`
+	// time of the last registration status change and reason of the last failure
+	RegistrationChangedAt     time.Time `toml:"registrationChangedAt,omitempty" json:"registrationChangedAt,omitempty" yaml:"registrationChangedAt,omitempty"`
+	RegistrationFailureReason string    `toml:"registrationFailureReason,omitempty" json:"registrationFailureReason,omitempty" yaml:"registrationFailureReason,omitempty"`
 
 	// Hardware box serial number
-	SerialNumber string `toml:"serialNumber"`
+	SerialNumber string `toml:"serialNumber" json:"serialNumber" yaml:"serialNumber"`
+
+	AgentSupportedVersion string `toml:"agentSupportedVersion" json:"agentSupportedVersion" yaml:"agentSupportedVersion"`
 
-	AgentSupportedVersion string `toml:"agentSupportedVersion"`
+	// dir the config was loaded from, set by Manager and never persisted
+	Dir string `toml:"-" json:"-" yaml:"-"`
+
+	// key provider used by Save and Reload, DefaultKeyProvider when nil
+	Keys KeyProvider `toml:"-" json:"-" yaml:"-"`
 }
 
 type ConfigManager struct {
 	Name string
 	Type string
 	Path string
+
+	// key provider used for encrypted values, DefaultKeyProvider when nil
+	Keys KeyProvider
+
+	// how long to wait for other processes to release the config, DefaultLockTimeout when zero
+	LockTimeout time.Duration
+
+	// migrations run on load before the config is decoded, none when nil
+	Migrations *Migrations
+
+	// used by Watch, DefaultWatchDebounce and DefaultWatchPollInterval when zero
+	WatchDebounce     time.Duration
+	WatchPollInterval time.Duration
 }
 
+const DefaultLockTimeout = 10 * time.Second
+
+var ErrConfigLocked = errors.New("config is locked by another process")
+
 func NewConfigManager(name string, configType string, path string) *ConfigManager {
 	return &ConfigManager{
 		Name: name,
@@ -221,11 +253,143 @@ func (C *ConfigManager) FullPath() string {
 	return filepath.Join(C.Path, C.Name+"."+C.Type)
 }
 
+// LockPath returns path to the file locked while the config is read or written
+func (C *ConfigManager) LockPath() string {
+	return C.FullPath() + ".lock"
+}
+
+// lock acquires the config lock shared between processes, exclusive for writes
+func (C *ConfigManager) lock(exclusive bool) (*utils.FileLock, error) {
+	timeout := C.LockTimeout
+	if timeout == 0 {
+		timeout = DefaultLockTimeout
+	}
+	lock, err := utils.LockFile(C.LockPath(), exclusive, timeout)
+	if errors.Is(err, utils.ErrFileLocked) {
+		return nil, fmt.Errorf("%w: %s", ErrConfigLocked, C.FullPath())
+	}
+	return lock, err
+}
+
+// lockForWrite creates the config dir and acquires the exclusive config lock
+func (C *ConfigManager) lockForWrite() (*utils.FileLock, error) {
+	dir := filepath.Dir(C.FullPath())
+	if !utils.FS.CreateDir(dir) {
+		return nil, fmt.Errorf("could not create dir: %s", dir)
+	}
+	return C.lock(true)
+}
+
+// BackupPath returns path to the copy of the last good config kept by Save
+func (C *ConfigManager) BackupPath() string {
+	return C.FullPath() + ".bak"
+}
+
 func (C *ConfigManager) Unmarshal(config interface{}) error {
-	_, err := toml.DecodeFile(C.FullPath(), config)
+	_, err := C.unmarshal(config)
 	return err
 }
 
+// unmarshal decodes the config and decrypts its secret fields, stale is true
+// when some of them should be re-encrypted
+func (C *ConfigManager) unmarshal(config interface{}) (bool, error) {
+	if err := C.decodeFile(C.FullPath(), config, false); err != nil {
+		return false, err
+	}
+	return openSecrets(config, C.keyProvider())
+}
+
+// decodeFile reads path, migrates it and decodes it into config. locked is
+// true when the caller holds the exclusive config lock, otherwise the file is
+// read under the shared lock.
+func (C *ConfigManager) decodeFile(path string, config interface{}, locked bool) error {
+	codec, err := LookupCodec(C.Type)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	if locked {
+		data, err = os.ReadFile(path)
+	} else {
+		data, err = C.readFile(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	if C.Migrations != nil {
+		data, err = C.migrate(codec, path, data, locked)
+		if err != nil {
+			return err
+		}
+	}
+	return codec.Unmarshal(data, config)
+}
+
+// readFile reads path under the shared config lock
+func (C *ConfigManager) readFile(path string) ([]byte, error) {
+	lock, err := C.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+	return os.ReadFile(path)
+}
+
+// migrate upgrades data read from path to the latest schema version and
+// persists the result. Without the exclusive lock the file is read and
+// migrated again once the lock is taken, it may have changed in the meantime.
+func (C *ConfigManager) migrate(codec Codec, path string, data []byte, locked bool) ([]byte, error) {
+	content := map[string]interface{}{}
+	if err := codec.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+
+	migrated, err := C.Migrations.Migrate(content)
+	if err != nil || !migrated {
+		return data, err
+	}
+
+	if !locked {
+		lock, err := C.lock(true)
+		if err != nil {
+			return nil, err
+		}
+		defer lock.Unlock()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return C.migrate(codec, path, data, true)
+	}
+
+	data, err = codec.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	logger.Logger.Infof("Migrated config %s to schema version %d", C.FullPath(), content[SchemaVersionKey])
+	return data, C.write(codec, data)
+}
+
+// decodeBackup replaces config with the content of the backup, err is the
+// error the primary file failed to decode with
+func (C *ConfigManager) decodeBackup(config interface{}, err error, locked bool) error {
+	if errors.Is(err, os.ErrNotExist) || !utils.FS.FileExists(C.BackupPath()) {
+		return err
+	}
+
+	logger.Logger.Warningf("Failed to decode config %s, restoring it from backup: %s", C.FullPath(), err)
+	rv := reflect.ValueOf(config)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+	}
+	if backupErr := C.decodeFile(C.BackupPath(), config, locked); backupErr != nil {
+		logger.Logger.Errorf("Failed to decode config backup %s: %s", C.BackupPath(), backupErr)
+		return err
+	}
+	return nil
+}
+
 type ConfigWithPassword interface {
 	GetPassword() string
 	SetPassword(value string)
@@ -233,13 +397,26 @@ type ConfigWithPassword interface {
 	SetPasswordEncrypted(value string)
 }
 
-var mutex sync.Mutex
+// field name the encrypted password of ConfigWithPassword is bound to
+const passwordField = "Password"
+
+// legacy key shared by all installs, only used to decrypt existing configs
 var encryptionKey = [32]byte{
 	188, 74, 186, 252, 160, 2, 151, 205, 140, 118, 207, 210, 51, 108, 180, 149,
 	213, 181, 104, 174, 206, 5, 190, 24, 153, 29, 195, 153, 235, 96, 250, 163,
 }
 
 func Encrypt(plaintext []byte, key *[32]byte) (ciphertext []byte, err error) {
+	return EncryptWithAdditionalData(plaintext, key, nil)
+}
+
+func Decrypt(ciphertext []byte, key *[32]byte) (plaintext []byte, err error) {
+	return DecryptWithAdditionalData(ciphertext, key, nil)
+}
+
+// EncryptWithAdditionalData authenticates additionalData along with the
+// ciphertext, the same data has to be passed to DecryptWithAdditionalData
+func EncryptWithAdditionalData(plaintext []byte, key *[32]byte, additionalData []byte) (ciphertext []byte, err error) {
 	block, err := aes.NewCipher(key[:])
 	if err != nil {
 		return nil, err
@@ -256,10 +433,10 @@ func Encrypt(plaintext []byte, key *[32]byte) (ciphertext []byte, err error) {
 		return nil, err
 	}
 
-	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
 }
 
-func Decrypt(ciphertext []byte, key *[32]byte) (plaintext []byte, err error) {
+func DecryptWithAdditionalData(ciphertext []byte, key *[32]byte, additionalData []byte) (plaintext []byte, err error) {
 	block, err := aes.NewCipher(key[:])
 	if err != nil {
 		return nil, err
@@ -277,7 +454,7 @@ func Decrypt(ciphertext []byte, key *[32]byte) (plaintext []byte, err error) {
 	return gcm.Open(nil,
 		ciphertext[:gcm.NonceSize()],
 		ciphertext[gcm.NonceSize():],
-		nil,
+		additionalData,
 	)
 }
 
@@ -293,22 +470,46 @@ func Base64Decode(str string) (string, bool) {
 	return string(data), false
 }
 
+// EncryptPassword encrypts password without binding it to an appliance.
+//
+// Deprecated: use EncryptPasswordFor, passwords saved by Config.Save are
+// bound to the appliance.
 func EncryptPassword(password string) (string, error) {
-	encrypted, error := Encrypt([]byte(password), &encryptionKey)
-	if error != nil {
-		return "", errors.New("failed to encrypt config data: " + error.Error())
-	}
-
-	return Base64Encode(string(encrypted)), nil
+	return SealEnvelope(password, DefaultKeyProvider)
 }
 
+// DecryptPassword decrypts values written by EncryptPassword and by versions
+// of the sdk before passwords were bound to the appliance.
+//
+// Deprecated: PasswordEncrypted saved by Config.Save can only be decrypted
+// with the context of the config, use DecryptPasswordFor. DecryptPassword
+// returns an error wrapping ErrContextMismatch for those values.
 func DecryptPassword(password string) (string, error) {
+	decrypted, _, err := OpenEnvelope(password, DefaultKeyProvider)
+	return decrypted, err
+}
+
+// EncryptPasswordFor encrypts password the way Config.Save stores it, context
+// is the one of the config like Config.EncryptionContext()
+func EncryptPasswordFor(context EncryptionContext, password string) (string, error) {
+	return SealEnvelopeWithContext(password, DefaultKeyProvider, context.WithField(passwordField))
+}
+
+// DecryptPasswordFor decrypts PasswordEncrypted of a config saved by
+// Config.Save, context is the one of the config like Config.EncryptionContext()
+func DecryptPasswordFor(context EncryptionContext, password string) (string, error) {
+	decrypted, _, err := OpenEnvelopeWithContext(password, DefaultKeyProvider, context.WithField(passwordField))
+	return decrypted, err
+}
+
+// decryptLegacy decrypts values written before the envelope format was introduced
+func decryptLegacy(password string, key *[32]byte) (string, error) {
 	decoded, hasError := Base64Decode(password)
 	if hasError {
 		return "", errors.New("failed to decode config data")
 	}
 
-	decrypted, error := Decrypt([]byte(decoded), &encryptionKey)
+	decrypted, error := Decrypt([]byte(decoded), key)
 	if error != nil {
 		return "", errors.New("failed to further decode config data")
 	}
@@ -317,75 +518,207 @@ func DecryptPassword(password string) (string, error) {
 	return result, nil
 }
 
-func (C *ConfigManager) LoadApplianceConfig(config ConfigWithPassword) error {
-	err := C.Unmarshal(config)
-	if err != nil {
+func (C *ConfigManager) keyProvider() KeyProvider {
+	if C.Keys != nil {
+		return C.Keys
+	}
+	return DefaultKeyProvider
+}
+
+// LoadApplianceConfig loads config and decrypts its password and secret fields.
+// Values stored in cleartext, in the legacy format or with a retired key are
+// re-encrypted with the current key. When the config fails to decode it is
+// restored from the backup written by Save. Failing to re-encrypt is only
+// logged, the decrypted config is still returned. The exclusive config lock
+// is held from reading to re-encrypting, unless the config dir is read-only.
+func (C *ConfigManager) LoadApplianceConfig(config interface{}) error {
+	locked := true
+	lock, err := C.lock(true)
+	if errors.Is(err, ErrConfigLocked) {
 		return err
 	}
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Logger.Warningf("Loading config %s without re-encrypting it: %s", C.FullPath(), err)
+		}
+		locked = false
+	} else {
+		defer lock.Unlock()
+	}
 
-	if config != nil {
-		if len(config.GetPassword()) > 0 {
-			err = C.SaveApplianceConfig(config)
-			if err != nil {
-				return err
-			}
+	restored := false
+	err = C.decodeFile(C.FullPath(), config, locked)
+	if err != nil {
+		err = C.decodeBackup(config, err, locked)
+		if err != nil {
+			return err
 		}
+		restored = true
+	}
 
-		encrypted := config.GetPasswordEncrypted()
-		if len(encrypted) > 0 {
-			decrypted, err := DecryptPassword(encrypted)
-			if err != nil {
-				return err
-			}
-			config.SetPassword(decrypted)
+	stale, err := openSecrets(config, C.keyProvider())
+	if err != nil {
+		return err
+	}
+	stale = stale || restored
+
+	expired, err := openPassword(config, C.keyProvider())
+	if err != nil {
+		return err
+	}
+	stale = stale || expired
+
+	withPassword, hasPassword := config.(ConfigWithPassword)
+	if stale && locked {
+		password := ""
+		if hasPassword {
+			password = withPassword.GetPassword()
+		}
+		if err := C.saveApplianceConfig(config); err != nil {
+			logger.Logger.Warningf("Failed to re-encrypt config %s: %s", C.FullPath(), err)
+		}
+		if hasPassword {
+			withPassword.SetPassword(password)
 		}
 	}
 
 	return nil
 }
 
+// openPassword decrypts PasswordEncrypted into the password of configs
+// implementing ConfigWithPassword. stale is true when the password is held in
+// cleartext or should be re-encrypted.
+func openPassword(config interface{}, keys KeyProvider) (stale bool, err error) {
+	withPassword, ok := config.(ConfigWithPassword)
+	if !ok {
+		return false, nil
+	}
+	if len(withPassword.GetPassword()) > 0 {
+		return true, nil
+	}
+	encrypted := withPassword.GetPasswordEncrypted()
+	if len(encrypted) == 0 {
+		return false, nil
+	}
+	decrypted, expired, err := OpenEnvelopeWithContext(encrypted, keys, contextOf(config).WithField(passwordField))
+	if err != nil {
+		return false, err
+	}
+	withPassword.SetPassword(decrypted)
+	return expired, nil
+}
+
+// Remove deletes the config and its backup, the lock file is kept as other
+// processes may be waiting on it
 func (C *ConfigManager) Remove() error {
+	lock, err := C.lock(true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	if utils.FS.FileExists(C.BackupPath()) {
+		if err := utils.FS.RemoveFile(C.BackupPath()); err != nil {
+			return err
+		}
+	}
 	return utils.FS.RemoveFile(C.FullPath())
 }
 
-func (C *ConfigManager) SaveApplianceConfig(config ConfigWithPassword) error {
-	if config != nil {
-		encrypted, err := EncryptPassword(config.GetPassword())
+// SaveApplianceConfig moves the password of configs implementing
+// ConfigWithPassword to PasswordEncrypted and saves the config
+func (C *ConfigManager) SaveApplianceConfig(config interface{}) error {
+	lock, err := C.lockForWrite()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	return C.saveApplianceConfig(config)
+}
+
+func (C *ConfigManager) saveApplianceConfig(config interface{}) error {
+	if withPassword, ok := config.(ConfigWithPassword); ok {
+		encrypted, err := SealEnvelopeWithContext(withPassword.GetPassword(), C.keyProvider(), contextOf(config).WithField(passwordField))
 		if err != nil {
 			return errors.New("failed to save config: " + err.Error())
 		}
 
-		config.SetPasswordEncrypted(encrypted)
-		config.SetPassword("")
+		withPassword.SetPasswordEncrypted(encrypted)
+		withPassword.SetPassword("")
 	}
 
-	return C.Save(config)
+	return C.save(config)
 }
 
+// Save writes config, fields tagged as secret are encrypted on the way
 func (C *ConfigManager) Save(config interface{}) error {
-	mutex.Lock()
-	dir := filepath.Dir(C.FullPath())
-	if !utils.FS.CreateDir(dir) {
-		return fmt.Errorf("could not create dir: %s", dir)
-	}
-	f, err := os.Create(C.FullPath())
+	lock, err := C.lockForWrite()
 	if err != nil {
-		mutex.Unlock()
 		return err
 	}
+	defer lock.Unlock()
+	return C.save(config)
+}
+
+func (C *ConfigManager) save(config interface{}) error {
+	config, err := sealSecrets(config, C.keyProvider())
+	if err != nil {
+		return errors.New("failed to save config: " + err.Error())
+	}
 
-	if err := toml.NewEncoder(f).Encode(config); err != nil {
-		mutex.Unlock()
+	if versioned, ok := config.(ConfigWithSchemaVersion); ok && C.Migrations != nil {
+		if latest := C.Migrations.Latest(); versioned.GetSchemaVersion() < latest {
+			versioned.SetSchemaVersion(latest)
+		}
+	}
+
+	codec, err := LookupCodec(C.Type)
+	if err != nil {
 		return err
 	}
-	if err := f.Close(); err != nil {
-		mutex.Unlock()
+	data, err := codec.Marshal(config)
+	if err != nil {
 		return err
 	}
-	mutex.Unlock()
+	return C.write(codec, data)
+}
+
+// write replaces the config with data, keeping the current one as backup.
+// The caller holds the exclusive config lock.
+func (C *ConfigManager) write(codec Codec, data []byte) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(C.FullPath()); err == nil {
+		perm = info.Mode().Perm()
+		C.backup(codec, perm)
+	}
+
+	if err := utils.FS.WriteFileAtomic(C.FullPath(), data, perm); err != nil {
+		return err
+	}
+	recordSelfWrite(C.FullPath(), data)
 	return nil
 }
 
+// backup copies the current config to BackupPath, unless it is damaged and
+// the previous backup is the last good config
+func (C *ConfigManager) backup(codec Codec, perm os.FileMode) {
+	data, err := os.ReadFile(C.FullPath())
+	if err != nil {
+		logger.Logger.Errorf("Failed to read config %s for backup: %s", C.FullPath(), err)
+		return
+	}
+
+	var content map[string]interface{}
+	if err := codec.Unmarshal(data, &content); err != nil {
+		logger.Logger.Warningf("Not backing up damaged config %s: %s", C.FullPath(), err)
+		return
+	}
+
+	if err := utils.FS.WriteFileAtomic(C.BackupPath(), data, perm); err != nil {
+		logger.Logger.Errorf("Failed to write config backup %s: %s", C.BackupPath(), err)
+	}
+}
+
 const (
 	ApplianceConfigName = "config"
 	ApplianceConfigType = "toml"
@@ -394,14 +727,36 @@ const (
 )
 
 func (C *Config) Save(dir string) error {
-	cfgMgr := NewConfigManager(ApplianceConfigName, ApplianceConfigType, dir)
 	configToSave := *C
-	return cfgMgr.SaveApplianceConfig(&configToSave)
+	return C.configManager(dir).SaveApplianceConfig(&configToSave)
 }
 
+// Reload loads the config from dir, Dir and Keys are kept
 func (C *Config) Reload(dir string) error {
+	configDir, keys := C.Dir, C.Keys
+	err := C.configManager(dir).LoadApplianceConfig(C)
+	C.Dir, C.Keys = configDir, keys
+	return err
+}
+
+func (C *Config) configManager(dir string) *ConfigManager {
 	cfgMgr := NewConfigManager(ApplianceConfigName, ApplianceConfigType, dir)
-	return cfgMgr.LoadApplianceConfig(C)
+	cfgMgr.Migrations = ApplianceMigrations
+	cfgMgr.Keys = C.Keys
+	return cfgMgr
+}
+
+func (C *Config) GetSchemaVersion() int {
+	return C.SchemaVersion
+}
+
+func (C *Config) SetSchemaVersion(version int) {
+	C.SchemaVersion = version
+}
+
+// EncryptionContext binds encrypted values of the config to the appliance
+func (C *Config) EncryptionContext() EncryptionContext {
+	return EncryptionContext{ApplianceId: C.Id, ApplianceType: C.Type}
 }
 
 func (C *Config) GetPassword() string {
@@ -422,9 +777,24 @@ func (C *Config) SetPasswordEncrypted(value string) {
 
 func (C *CommonConfig) Save(dir string) error {
 	cfgMgr := NewConfigManager(CommonConfigName, CommonConfigType, dir)
+	cfgMgr.Migrations = CommonMigrations
 	return cfgMgr.Save(C)
 }
 
+func (C *CommonConfig) Reload(dir string) error {
+	cfgMgr := NewConfigManager(CommonConfigName, CommonConfigType, dir)
+	cfgMgr.Migrations = CommonMigrations
+	return cfgMgr.Unmarshal(C)
+}
+
+func (C *CommonConfig) GetSchemaVersion() int {
+	return C.SchemaVersion
+}
+
+func (C *CommonConfig) SetSchemaVersion(version int) {
+	C.SchemaVersion = version
+}
+
 // GetSupportedVersion returns the supported version of the appliance
 func (C *Config) GetAgentSupportedVersion() string {
 	return C.AgentSupportedVersion
@@ -436,11 +806,14 @@ func (C *Config) SetAgentSupportedVersion(value string) {
 }
 
 type CommonConfig struct {
+	// version of the config schema, see CommonMigrations
+	SchemaVersion int `toml:"schemaVersion" json:"schemaVersion" yaml:"schemaVersion"`
+
 	// unique identifier for the machine
-	MachineId string `toml:"machineId"`
+	MachineId string `toml:"machineId" json:"machineId" yaml:"machineId"`
 	// enable or disable agent auto update
-	EnableUpdate *bool `toml:"enableUpdate"`
-	EnableSentry bool  `toml:"enableSentry"`
+	EnableUpdate *bool `toml:"enableUpdate" json:"enableUpdate" yaml:"enableUpdate"`
+	EnableSentry bool  `toml:"enableSentry" json:"enableSentry" yaml:"enableSentry"`
 }
 
 type MetricInsight struct {
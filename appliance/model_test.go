@@ -0,0 +1,107 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/utils"
+)
+
+func TestSaveKeepsBackup(t *testing.T) {
+	assert := assert.New(t)
+	cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, t.TempDir())
+	cfgMgr.Keys = appliance.NewMachineKeyProvider("machine-a")
+
+	assert.NoError(cfgMgr.SaveApplianceConfig(&appliance.Config{Id: "1", ServerDir: "/opt/first"}))
+	assert.False(fileExists(cfgMgr.BackupPath()))
+
+	assert.NoError(cfgMgr.SaveApplianceConfig(&appliance.Config{Id: "1", ServerDir: "/opt/second"}))
+	data, err := os.ReadFile(cfgMgr.BackupPath())
+	assert.NoError(err)
+	backup := &appliance.Config{}
+	assert.NoError((&appliance.TOMLCodec{}).Unmarshal(data, backup))
+	assert.Equal("/opt/first", backup.ServerDir)
+
+	// a damaged config is never backed up over the last good one
+	assert.NoError(os.WriteFile(cfgMgr.FullPath(), []byte("id = \"1"), 0644))
+	loaded := &appliance.Config{}
+	assert.NoError(cfgMgr.LoadApplianceConfig(loaded))
+	assert.Equal("/opt/first", loaded.ServerDir)
+
+	restored := &appliance.Config{}
+	assert.NoError(cfgMgr.Unmarshal(restored))
+	assert.Equal("/opt/first", restored.ServerDir)
+
+	assert.NoError(cfgMgr.Remove())
+	assert.False(fileExists(cfgMgr.FullPath()))
+	assert.False(fileExists(cfgMgr.BackupPath()))
+}
+
+func TestLoadMissingConfigIgnoresBackup(t *testing.T) {
+	assert := assert.New(t)
+	cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, t.TempDir())
+	assert.NoError(os.WriteFile(cfgMgr.BackupPath(), []byte("id = \"1\"\n"), 0644))
+
+	err := cfgMgr.LoadApplianceConfig(&appliance.Config{})
+	assert.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestConfigLocked(t *testing.T) {
+	assert := assert.New(t)
+	cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, t.TempDir())
+	cfgMgr.LockTimeout = 100 * time.Millisecond
+	assert.NoError(cfgMgr.Save(&appliance.CommonConfig{MachineId: "1"}))
+
+	lock, err := utils.LockFile(cfgMgr.LockPath(), false, time.Second)
+	assert.NoError(err)
+
+	// readers share the lock, writers wait for it
+	assert.NoError(cfgMgr.Unmarshal(&appliance.CommonConfig{}))
+	assert.ErrorIs(cfgMgr.Save(&appliance.CommonConfig{MachineId: "2"}), appliance.ErrConfigLocked)
+
+	// loading may re-encrypt the config, so it waits as well
+	assert.ErrorIs(cfgMgr.LoadApplianceConfig(&appliance.CommonConfig{}), appliance.ErrConfigLocked)
+
+	assert.NoError(lock.Unlock())
+	assert.NoError(cfgMgr.Save(&appliance.CommonConfig{MachineId: "2"}))
+
+	lock, err = utils.LockFile(cfgMgr.LockPath(), true, time.Second)
+	assert.NoError(err)
+	assert.ErrorIs(cfgMgr.Unmarshal(&appliance.CommonConfig{}), appliance.ErrConfigLocked)
+	assert.NoError(lock.Unlock())
+}
+
+func TestReadOnlyConfigDir(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	assert := assert.New(t)
+	dir := t.TempDir()
+	keys := testKeys(t)
+	assert.NoError((&appliance.Config{Id: "1", Password: "secret", Keys: keys}).Save(dir))
+	cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, dir)
+	assert.NoError(os.Remove(cfgMgr.LockPath()))
+	assert.NoError(os.Chmod(dir, 0555))
+	t.Cleanup(func() { os.Chmod(dir, 0755) })
+
+	assert.NoError(cfgMgr.Unmarshal(&appliance.Config{}))
+	loaded := &appliance.Config{Keys: keys}
+	assert.NoError(loaded.Reload(dir))
+	assert.Equal("secret", loaded.Password)
+	assert.False(fileExists(cfgMgr.LockPath()))
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,152 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NotificationBuilder creates validated notifications, see NewNotification
+type NotificationBuilder struct {
+	notification Notification
+	err          error
+}
+
+// NewNotification starts a notification of the appliance with id applianceId.
+// Build fails unless type, name and a known severity are set.
+func NewNotification(applianceId string) *NotificationBuilder {
+	return &NotificationBuilder{notification: Notification{ApplianceId: applianceId}}
+}
+
+func (B *NotificationBuilder) Type(notificationType string) *NotificationBuilder {
+	B.notification.Type = notificationType
+	return B
+}
+
+func (B *NotificationBuilder) Name(name string) *NotificationBuilder {
+	B.notification.Name = name
+	return B
+}
+
+// Severity parses severity, an unknown one makes Build fail
+func (B *NotificationBuilder) Severity(severity string) *NotificationBuilder {
+	parsed, err := ParseSeverity(severity)
+	if err != nil && B.err == nil {
+		B.err = err
+	}
+	B.notification.Severity = parsed
+	return B
+}
+
+func (B *NotificationBuilder) Message(format string, args ...interface{}) *NotificationBuilder {
+	B.notification.Message = fmt.Sprintf(format, args...)
+	return B
+}
+
+func (B *NotificationBuilder) Dimension(name string, value string) *NotificationBuilder {
+	B.notification.Dimensions = append(B.notification.Dimensions, &Dimension{Name: name, Value: value})
+	return B
+}
+
+// At sets the timestamp, Build uses the current time otherwise
+func (B *NotificationBuilder) At(timestamp time.Time) *NotificationBuilder {
+	B.notification.Timestamp = JSONTime(timestamp)
+	return B
+}
+
+func (B *NotificationBuilder) Build() (*Notification, error) {
+	if B.err != nil {
+		return nil, B.err
+	}
+	missing := []string{}
+	if len(B.notification.ApplianceId) == 0 {
+		missing = append(missing, "applianceId")
+	}
+	if len(B.notification.Type) == 0 {
+		missing = append(missing, "type")
+	}
+	if len(B.notification.Name) == 0 {
+		missing = append(missing, "name")
+	}
+	if len(B.notification.Severity) == 0 {
+		missing = append(missing, "severity")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("notification is missing %s", strings.Join(missing, ", "))
+	}
+
+	notification := B.notification
+	notification.Dimensions = append([]*Dimension{}, B.notification.Dimensions...)
+	if time.Time(notification.Timestamp).IsZero() {
+		notification.Timestamp = JSONTime(time.Now().UTC())
+	}
+	return &notification, nil
+}
+
+// NotificationFilter selects notifications for a consumer
+type NotificationFilter func(notification *Notification) bool
+
+// SeverityAtLeast selects notifications of severity or higher
+func SeverityAtLeast(severity Severity) NotificationFilter {
+	return func(notification *Notification) bool {
+		return notification.Severity.IsValid() && notification.Severity.Compare(severity) >= 0
+	}
+}
+
+// operators of ParseSeverityFilter, two character ones first so they match before their prefixes
+var severityOperators = []struct {
+	operator string
+	matches  func(compared int) bool
+}{
+	{">=", func(c int) bool { return c >= 0 }},
+	{"<=", func(c int) bool { return c <= 0 }},
+	{"!=", func(c int) bool { return c != 0 }},
+	{"==", func(c int) bool { return c == 0 }},
+	{">", func(c int) bool { return c > 0 }},
+	{"<", func(c int) bool { return c < 0 }},
+	{"=", func(c int) bool { return c == 0 }},
+}
+
+// ParseSeverityFilter parses expressions like "severity >= Warning", the
+// operators are >=, >, <=, <, ==, = and !=. Notifications of unknown severity
+// are never selected.
+func ParseSeverityFilter(expression string) (NotificationFilter, error) {
+	rest := strings.TrimSpace(expression)
+	if len(rest) < len("severity") || !strings.EqualFold(rest[:len("severity")], "severity") {
+		return nil, fmt.Errorf("invalid severity filter '%s': must start with severity", expression)
+	}
+	rest = strings.TrimSpace(rest[len("severity"):])
+
+	for _, op := range severityOperators {
+		if !strings.HasPrefix(rest, op.operator) {
+			continue
+		}
+		severity, err := ParseSeverity(rest[len(op.operator):])
+		if err != nil {
+			return nil, fmt.Errorf("invalid severity filter '%s': %w", expression, err)
+		}
+		matches := op.matches
+		return func(notification *Notification) bool {
+			return notification.Severity.IsValid() && matches(notification.Severity.Compare(severity))
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid severity filter '%s': unknown operator", expression)
+}
+
+// FilterNotifications returns the notifications selected by filter
+func FilterNotifications(notifications []*Notification, filter NotificationFilter) []*Notification {
+	selected := []*Notification{}
+	for _, notification := range notifications {
+		if notification != nil && filter(notification) {
+			selected = append(selected, notification)
+		}
+	}
+	return selected
+}
@@ -0,0 +1,69 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+func TestNewNotification(t *testing.T) {
+	assert := assert.New(t)
+	notification, err := appliance.NewNotification("1").
+		Type("license").
+		Name("LicenseExpiring").
+		Severity("warn").
+		Message("license expires in %d days", 5).
+		Dimension("product", "Kerio-Connect").
+		Build()
+	assert.NoError(err)
+	assert.Equal("1", notification.ApplianceId)
+	assert.Equal(appliance.SeverityWarning, notification.Severity)
+	assert.Equal("license expires in 5 days", notification.Message)
+	assert.WithinDuration(time.Now(), time.Time(notification.Timestamp), time.Minute)
+
+	_, err = appliance.NewNotification("1").Type("license").Name("LicenseExpiring").Severity("Warnign").Build()
+	assert.ErrorIs(err, appliance.ErrUnknownSeverity)
+	_, err = appliance.NewNotification("").Type("license").Build()
+	assert.EqualError(err, "notification is missing applianceId, name, severity")
+}
+
+func TestParseSeverityFilter(t *testing.T) {
+	assert := assert.New(t)
+	notifications := []*appliance.Notification{
+		{Name: "a", Severity: appliance.SeverityInfo},
+		{Name: "b", Severity: appliance.SeverityWarning},
+		{Name: "c", Severity: appliance.SeverityCritical},
+		{Name: "d", Severity: "bogus"},
+	}
+	names := func(expression string) []string {
+		filter, err := appliance.ParseSeverityFilter(expression)
+		assert.NoError(err)
+		result := []string{}
+		for _, notification := range appliance.FilterNotifications(notifications, filter) {
+			result = append(result, notification.Name)
+		}
+		return result
+	}
+
+	assert.Equal([]string{"b", "c"}, names("severity >= Warning"))
+	assert.Equal([]string{"c"}, names("Severity>warn"))
+	assert.Equal([]string{"a"}, names("severity < Warning"))
+	assert.Equal([]string{"a", "c"}, names("severity != Warning"))
+	assert.Equal([]string{"b"}, names("severity = warning"))
+
+	_, err := appliance.ParseSeverityFilter("severity ~ Warning")
+	assert.Error(err)
+	_, err = appliance.ParseSeverityFilter("level >= Warning")
+	assert.Error(err)
+	_, err = appliance.ParseSeverityFilter("severity >= loud")
+	assert.ErrorIs(err, appliance.ErrUnknownSeverity)
+}
@@ -0,0 +1,177 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+// Package notify turns periodically polled notifications into a stream of
+// changes: repeats are suppressed, persistent conditions escalate and
+// conditions which disappear are reported as resolved
+package notify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+const (
+	DefaultCooldown = time.Hour
+
+	// dimension added to resolved notifications
+	StatusDimension = "status"
+	StatusResolved  = "resolved"
+)
+
+// Escalation raises the severity of a condition which persisted for After
+type Escalation struct {
+	After    time.Duration
+	Severity appliance.Severity
+}
+
+// Stage processes the notifications of one appliance. Each call to Process
+// gets the complete result of a poll, conditions missing from it are resolved.
+// It is safe for concurrent use.
+type Stage struct {
+	// repeats of a condition are dropped until Cooldown passed since it was last
+	// sent, 0 uses DefaultCooldown and a negative cooldown sends every repeat
+	Cooldown    time.Duration
+	Escalations []Escalation
+
+	// severity of resolved notifications
+	ResolvedSeverity appliance.Severity
+
+	mu         sync.Mutex
+	conditions map[string]*condition
+}
+
+type condition struct {
+	notification *appliance.Notification
+	since        time.Time
+	sentAt       time.Time
+	severity     appliance.Severity
+}
+
+// NewStage returns a stage using DefaultCooldown when cooldown is 0, a
+// negative cooldown sends every repeat
+func NewStage(cooldown time.Duration, escalations ...Escalation) *Stage {
+	if cooldown == 0 {
+		cooldown = DefaultCooldown
+	}
+	return &Stage{
+		Cooldown:         cooldown,
+		Escalations:      escalations,
+		ResolvedSeverity: appliance.SeverityInfo,
+		conditions:       map[string]*condition{},
+	}
+}
+
+// Process returns the notifications to send for a poll made at now
+func (S *Stage) Process(notifications []*appliance.Notification, now time.Time) []*appliance.Notification {
+	S.mu.Lock()
+	defer S.mu.Unlock()
+	if S.conditions == nil {
+		S.conditions = map[string]*condition{}
+	}
+
+	out := []*appliance.Notification{}
+	seen := map[string]bool{}
+	for _, notification := range notifications {
+		if notification == nil {
+			continue
+		}
+		fingerprint := Fingerprint(notification)
+		if seen[fingerprint] {
+			continue
+		}
+		seen[fingerprint] = true
+
+		c, ok := S.conditions[fingerprint]
+		if !ok {
+			c = &condition{notification: notification, since: now, sentAt: now, severity: notification.Severity}
+			S.conditions[fingerprint] = c
+			out = append(out, notification)
+			continue
+		}
+		c.notification = notification
+
+		severity := S.escalate(notification.Severity, now.Sub(c.since))
+		if severity.Compare(c.severity) > 0 || !now.Before(c.sentAt.Add(S.cooldown())) {
+			c.severity = severity
+			c.sentAt = now
+			escalated := *notification
+			escalated.Severity = severity
+			out = append(out, &escalated)
+		}
+	}
+
+	fingerprints := []string{}
+	for fingerprint := range S.conditions {
+		if !seen[fingerprint] {
+			fingerprints = append(fingerprints, fingerprint)
+		}
+	}
+	sort.Strings(fingerprints)
+	for _, fingerprint := range fingerprints {
+		out = append(out, S.resolved(S.conditions[fingerprint].notification, now))
+		delete(S.conditions, fingerprint)
+	}
+	return out
+}
+
+// Active returns the number of conditions which have not been resolved yet
+func (S *Stage) Active() int {
+	S.mu.Lock()
+	defer S.mu.Unlock()
+	return len(S.conditions)
+}
+
+func (S *Stage) cooldown() time.Duration {
+	if S.Cooldown == 0 {
+		return DefaultCooldown
+	}
+	return S.Cooldown
+}
+
+// escalate returns the highest severity reached after persisting for duration
+func (S *Stage) escalate(severity appliance.Severity, duration time.Duration) appliance.Severity {
+	for _, escalation := range S.Escalations {
+		if duration >= escalation.After && escalation.Severity.Compare(severity) > 0 {
+			severity = escalation.Severity
+		}
+	}
+	return severity
+}
+
+func (S *Stage) resolved(notification *appliance.Notification, now time.Time) *appliance.Notification {
+	resolved := *notification
+	resolved.Severity = S.ResolvedSeverity
+	resolved.Message = "Resolved: " + notification.Message
+	resolved.Timestamp = appliance.JSONTime(now)
+	resolved.Dimensions = append(append([]*appliance.Dimension{}, notification.Dimensions...),
+		&appliance.Dimension{Name: StatusDimension, Value: StatusResolved})
+	return &resolved
+}
+
+// Fingerprint identifies the condition a notification reports by its type,
+// name and dimensions, the order of dimensions does not matter
+func Fingerprint(notification *appliance.Notification) string {
+	dimensions := []string{}
+	for _, dimension := range notification.Dimensions {
+		if dimension != nil {
+			dimensions = append(dimensions, dimension.Name+"\x00"+dimension.Value)
+		}
+	}
+	sort.Strings(dimensions)
+
+	hash := sha256.New()
+	hash.Write([]byte(notification.Type + "\x00" + notification.Name + "\x00"))
+	hash.Write([]byte(strings.Join(dimensions, "\x00")))
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,101 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package notify_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance/notify"
+)
+
+func licenseExpiring(dimensions ...*appliance.Dimension) *appliance.Notification {
+	return &appliance.Notification{
+		Type:       "license",
+		Name:       "LicenseExpiring",
+		Severity:   "Warning",
+		Message:    "license expires in 5 days",
+		Dimensions: dimensions,
+	}
+}
+
+func TestStage(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stage := notify.NewStage(time.Hour, notify.Escalation{After: 2 * time.Hour, Severity: "Critical"})
+	a := &appliance.Dimension{Name: "a", Value: "1"}
+	b := &appliance.Dimension{Name: "b", Value: "2"}
+
+	out := stage.Process([]*appliance.Notification{licenseExpiring(a, b), licenseExpiring(b, a)}, start)
+	assert.Len(out, 1)
+
+	// repeats are suppressed until the cooldown passed
+	assert.Empty(stage.Process([]*appliance.Notification{licenseExpiring(a, b)}, start.Add(30*time.Minute)))
+	out = stage.Process([]*appliance.Notification{licenseExpiring(a, b)}, start.Add(time.Hour))
+	assert.Len(out, 1)
+	assert.Equal(appliance.SeverityWarning, out[0].Severity)
+
+	// escalation is sent right away
+	out = stage.Process([]*appliance.Notification{licenseExpiring(a, b)}, start.Add(2*time.Hour))
+	assert.Len(out, 1)
+	assert.Equal(appliance.SeverityCritical, out[0].Severity)
+	assert.Empty(stage.Process([]*appliance.Notification{licenseExpiring(a, b)}, start.Add(150*time.Minute)))
+	assert.Equal(1, stage.Active())
+
+	out = stage.Process(nil, start.Add(3*time.Hour))
+	assert.Len(out, 1)
+	assert.Equal(appliance.SeverityInfo, out[0].Severity)
+	assert.Equal("Resolved: license expires in 5 days", out[0].Message)
+	assert.Equal(start.Add(3*time.Hour), time.Time(out[0].Timestamp))
+	assert.Equal(notify.StatusResolved, out[0].Dimensions[2].Value)
+	assert.Equal(0, stage.Active())
+}
+
+func TestEscalationNeverLowersSeverity(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Now()
+	stage := notify.NewStage(time.Hour, notify.Escalation{After: time.Minute, Severity: "Warning"})
+	critical := licenseExpiring()
+	critical.Severity = "Critical"
+
+	stage.Process([]*appliance.Notification{critical}, start)
+	out := stage.Process([]*appliance.Notification{critical}, start.Add(time.Hour))
+	assert.Len(out, 1)
+	assert.Equal(appliance.SeverityCritical, out[0].Severity)
+	assert.NotEqual(notify.Fingerprint(critical), notify.Fingerprint(licenseExpiring(&appliance.Dimension{Name: "a"})))
+}
+
+func TestDefaultCooldown(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Now()
+	stage := notify.NewStage(0)
+	assert.Equal(notify.DefaultCooldown, stage.Cooldown)
+
+	assert.Len(stage.Process([]*appliance.Notification{licenseExpiring()}, start), 1)
+	assert.Empty(stage.Process([]*appliance.Notification{licenseExpiring()}, start.Add(time.Minute)))
+	assert.Len(stage.Process([]*appliance.Notification{licenseExpiring()}, start.Add(notify.DefaultCooldown)), 1)
+
+	stage = notify.NewStage(-1)
+	assert.Len(stage.Process([]*appliance.Notification{licenseExpiring()}, start), 1)
+	assert.Len(stage.Process([]*appliance.Notification{licenseExpiring()}, start), 1)
+}
+
+func TestZeroStage(t *testing.T) {
+	assert := assert.New(t)
+	start := time.Now()
+	stage := &notify.Stage{}
+
+	assert.Len(stage.Process([]*appliance.Notification{licenseExpiring()}, start), 1)
+	assert.Empty(stage.Process([]*appliance.Notification{licenseExpiring()}, start.Add(time.Minute)))
+	assert.Len(stage.Process([]*appliance.Notification{licenseExpiring()}, start.Add(notify.DefaultCooldown)), 1)
+	assert.Equal(1, stage.Active())
+	assert.Len(stage.Process(nil, start.Add(notify.DefaultCooldown)), 1)
+	assert.Equal(0, stage.Active())
+}
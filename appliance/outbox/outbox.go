@@ -0,0 +1,288 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+// Package outbox keeps insights and notifications on disk until they are
+// published, so restarts and network outages do not lose them
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/constants"
+	"github.com/trilogy-group/gfi-agent-sdk/logger"
+	"github.com/trilogy-group/gfi-agent-sdk/utils"
+)
+
+const (
+	DefaultMaxBytes = 64 * 1024 * 1024
+	DefaultMaxAge   = 7 * 24 * time.Hour
+
+	entryExt = ".json"
+)
+
+var DefaultDir = filepath.Join(constants.GFIAgentDataDir, "outbox")
+
+var ErrEntryTooLarge = errors.New("outbox entry exceeds the size limit")
+
+// Batch is one appended entry, handed out by Dequeue until it is acked
+type Batch struct {
+	Seq           uint64
+	CreatedAt     time.Time
+	Attempts      int
+	Insights      []*appliance.Insight
+	Notifications []*appliance.Notification
+}
+
+// Outbox is a spool of batches in a dir, one file per batch. Dequeued
+// batches stay on disk until acked, after a restart all of them are pending
+// again. It is safe for concurrent use within one process.
+type Outbox struct {
+	Dir string
+
+	// limits enforced on Append by evicting the oldest batches, zero disables them
+	MaxBytes int64
+	MaxAge   time.Duration
+
+	mu      sync.Mutex
+	entries []*entry
+	nextSeq uint64
+}
+
+type entry struct {
+	seq       uint64
+	size      int64
+	createdAt time.Time
+	inFlight  bool
+	attempts  int
+}
+
+// Open loads the batches left in dir by a previous run, partially written
+// and unreadable files are removed
+func Open(dir string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	O := &Outbox{
+		Dir:      dir,
+		MaxBytes: DefaultMaxBytes,
+		MaxAge:   DefaultMaxAge,
+		nextSeq:  1,
+	}
+	if err := O.recover(); err != nil {
+		return nil, err
+	}
+	return O, nil
+}
+
+func (O *Outbox) recover() error {
+	files, err := os.ReadDir(O.Dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name := file.Name()
+		path := filepath.Join(O.Dir, name)
+		if file.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(name, ".") {
+			// temp file of an interrupted write
+			os.Remove(path)
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, entryExt), 10, 64)
+		if err != nil || !strings.HasSuffix(name, entryExt) {
+			continue
+		}
+
+		batch, size, err := readBatch(path)
+		if err != nil {
+			logger.Logger.Warningf("Removing unreadable outbox entry %s: %s", path, err)
+			os.Remove(path)
+			continue
+		}
+		O.entries = append(O.entries, &entry{seq: seq, size: size, createdAt: batch.CreatedAt})
+		if seq >= O.nextSeq {
+			O.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(O.entries, func(i, j int) bool { return O.entries[i].seq < O.entries[j].seq })
+	return nil
+}
+
+// Append stores insights and notifications as a new batch and evicts the
+// oldest batches exceeding MaxAge or MaxBytes
+func (O *Outbox) Append(insights []*appliance.Insight, notifications []*appliance.Notification) error {
+	if len(insights) == 0 && len(notifications) == 0 {
+		return nil
+	}
+
+	O.mu.Lock()
+	defer O.mu.Unlock()
+	batch := &Batch{
+		Seq:           O.nextSeq,
+		CreatedAt:     time.Now().UTC(),
+		Insights:      insights,
+		Notifications: notifications,
+	}
+	// stored times do not depend on the wire format of the exporters
+	data, err := appliance.MarshalJSONWithTimeFormat(newStoredBatch(batch), appliance.TimeFormatRFC3339Nano)
+	if err != nil {
+		return err
+	}
+	if O.MaxBytes > 0 && int64(len(data)) > O.MaxBytes {
+		return fmt.Errorf("%w: %d > %d bytes", ErrEntryTooLarge, len(data), O.MaxBytes)
+	}
+	if err := utils.FS.WriteFileAtomic(O.path(batch.Seq), data, 0600); err != nil {
+		return err
+	}
+
+	O.nextSeq++
+	O.entries = append(O.entries, &entry{seq: batch.Seq, size: int64(len(data)), createdAt: batch.CreatedAt})
+	O.evict()
+	return nil
+}
+
+// Dequeue hands out up to max pending batches, oldest first. They are not
+// handed out again until they are nacked or the outbox is reopened.
+func (O *Outbox) Dequeue(max int) ([]*Batch, error) {
+	O.mu.Lock()
+	defer O.mu.Unlock()
+	O.evict()
+
+	batches := []*Batch{}
+	kept := O.entries[:0]
+	for _, e := range O.entries {
+		if e.inFlight || len(batches) >= max {
+			kept = append(kept, e)
+			continue
+		}
+		batch, _, err := readBatch(O.path(e.seq))
+		if err != nil {
+			logger.Logger.Warningf("Dropping unreadable outbox entry %d: %s", e.seq, err)
+			os.Remove(O.path(e.seq))
+			continue
+		}
+		e.inFlight = true
+		e.attempts++
+		batch.Seq = e.seq
+		batch.Attempts = e.attempts
+		batches = append(batches, batch)
+		kept = append(kept, e)
+	}
+	O.entries = kept
+	return batches, nil
+}
+
+// Ack removes published batches, unknown ones were already evicted and are ignored
+func (O *Outbox) Ack(seqs ...uint64) error {
+	O.mu.Lock()
+	defer O.mu.Unlock()
+	errs := []error{}
+	for _, seq := range seqs {
+		if O.remove(seq) {
+			if err := utils.FS.RemoveFile(O.path(seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Nack returns batches which failed to publish to the pending ones
+func (O *Outbox) Nack(seqs ...uint64) {
+	O.mu.Lock()
+	defer O.mu.Unlock()
+	for _, seq := range seqs {
+		for _, e := range O.entries {
+			if e.seq == seq {
+				e.inFlight = false
+			}
+		}
+	}
+}
+
+// Len returns the number of stored batches, including the dequeued ones
+func (O *Outbox) Len() int {
+	O.mu.Lock()
+	defer O.mu.Unlock()
+	return len(O.entries)
+}
+
+// Size returns the bytes taken by the stored batches
+func (O *Outbox) Size() int64 {
+	O.mu.Lock()
+	defer O.mu.Unlock()
+	return O.size()
+}
+
+func (O *Outbox) size() int64 {
+	var size int64
+	for _, e := range O.entries {
+		size += e.size
+	}
+	return size
+}
+
+// evict drops the oldest batches until the limits are met, dequeued ones included
+func (O *Outbox) evict() {
+	evicted := 0
+	cutoff := time.Now().Add(-O.MaxAge)
+	for len(O.entries) > 0 {
+		oldest := O.entries[0]
+		expired := O.MaxAge > 0 && oldest.createdAt.Before(cutoff)
+		full := O.MaxBytes > 0 && O.size() > O.MaxBytes
+		if !expired && !full {
+			break
+		}
+		if err := utils.FS.RemoveFile(O.path(oldest.seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Logger.Errorf("Failed to evict outbox entry %d: %s", oldest.seq, err)
+			break
+		}
+		O.entries = O.entries[1:]
+		evicted++
+	}
+	if evicted > 0 {
+		logger.Logger.Warningf("Evicted %d unpublished outbox entries from %s", evicted, O.Dir)
+	}
+}
+
+func (O *Outbox) remove(seq uint64) bool {
+	for i, e := range O.entries {
+		if e.seq == seq {
+			O.entries = append(O.entries[:i], O.entries[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (O *Outbox) path(seq uint64) string {
+	return filepath.Join(O.Dir, fmt.Sprintf("%020d%s", seq, entryExt))
+}
+
+func readBatch(path string) (*Batch, int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	stored := &storedBatch{}
+	if err := json.Unmarshal(data, stored); err != nil {
+		return nil, 0, err
+	}
+	return stored.batch(), int64(len(data)), nil
+}
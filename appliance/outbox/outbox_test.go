@@ -0,0 +1,128 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package outbox_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance/outbox"
+)
+
+func insight(name string) *appliance.Insight {
+	return &appliance.Insight{
+		Name:      name,
+		Metric:    &appliance.Metric{Unit: "Count", Value: 1},
+		Timestamp: appliance.JSONTime(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+	}
+}
+
+func TestAckNackAndRecovery(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	box, err := outbox.Open(dir)
+	assert.NoError(err)
+
+	assert.NoError(box.Append([]*appliance.Insight{insight("a")}, nil))
+	assert.NoError(box.Append(nil, []*appliance.Notification{{Name: "b", Severity: "Warning"}, {Name: "b", Severity: "High"}}))
+	assert.NoError(box.Append([]*appliance.Insight{insight("c")}, nil))
+	assert.NoError(box.Append(nil, nil))
+	assert.Equal(3, box.Len())
+
+	batches, err := box.Dequeue(2)
+	assert.NoError(err)
+	assert.Len(batches, 2)
+	assert.Equal("a", batches[0].Insights[0].Name)
+	assert.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), time.Time(batches[0].Insights[0].Timestamp))
+	assert.Equal(appliance.SeverityWarning, batches[1].Notifications[0].Severity)
+	// severities unknown to the sdk are kept
+	assert.Equal(appliance.Severity("High"), batches[1].Notifications[1].Severity)
+
+	assert.NoError(box.Ack(batches[0].Seq))
+	box.Nack(batches[1].Seq)
+
+	batches, err = box.Dequeue(10)
+	assert.NoError(err)
+	assert.Len(batches, 2)
+	assert.Equal("b", batches[0].Notifications[0].Name)
+	assert.Equal(2, batches[0].Attempts)
+
+	// in flight batches are pending again after a restart, leftovers of
+	// interrupted writes are dropped
+	assert.NoError(os.WriteFile(filepath.Join(dir, ".00000000000000000009.json.tmp-1"), []byte("{"), 0600))
+	assert.NoError(os.WriteFile(filepath.Join(dir, "00000000000000000008.json"), []byte("{"), 0600))
+	reopened, err := outbox.Open(dir)
+	assert.NoError(err)
+	assert.Equal(2, reopened.Len())
+	batches, err = reopened.Dequeue(10)
+	assert.NoError(err)
+	assert.Len(batches, 2)
+	assert.Equal("c", batches[1].Insights[0].Name)
+
+	assert.NoError(reopened.Append([]*appliance.Insight{insight("d")}, nil))
+	batches, err = reopened.Dequeue(10)
+	assert.NoError(err)
+	assert.Equal(uint64(4), batches[0].Seq)
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(err)
+	assert.Len(files, 3)
+}
+
+func TestEviction(t *testing.T) {
+	assert := assert.New(t)
+	box, err := outbox.Open(t.TempDir())
+	assert.NoError(err)
+
+	assert.NoError(box.Append([]*appliance.Insight{insight("a")}, nil))
+	// room for two entries, their sizes differ by a few bytes of timestamp
+	box.MaxBytes = box.Size()*2 + box.Size()/2
+	assert.NoError(box.Append([]*appliance.Insight{insight("b")}, nil))
+	assert.NoError(box.Append([]*appliance.Insight{insight("c")}, nil))
+	assert.Equal(2, box.Len())
+
+	batches, err := box.Dequeue(10)
+	assert.NoError(err)
+	assert.Equal("b", batches[0].Insights[0].Name)
+
+	box.MaxBytes = 10
+	assert.ErrorIs(box.Append([]*appliance.Insight{insight("d")}, nil), outbox.ErrEntryTooLarge)
+
+	box.MaxBytes = 0
+	box.MaxAge = 50 * time.Millisecond
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(box.Append([]*appliance.Insight{insight("e")}, nil))
+	assert.Equal(1, box.Len())
+}
+
+func TestStoredTimesIgnoreDefaultFormat(t *testing.T) {
+	assert := assert.New(t)
+	t.Cleanup(func() { appliance.SetTimeFormat(appliance.TimeFormatRFC3339Nano) })
+	appliance.SetTimeFormat(appliance.TimeFormatEpochSeconds)
+	dir := t.TempDir()
+	box, err := outbox.Open(dir)
+	assert.NoError(err)
+
+	stored := insight("a")
+	stored.Timestamp = appliance.JSONTime(time.Date(2024, 1, 2, 3, 4, 5, 678000000, time.UTC))
+	assert.NoError(box.Append([]*appliance.Insight{stored}, nil))
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.NoError(err)
+	assert.Len(files, 1)
+	data, err := os.ReadFile(files[0])
+	assert.NoError(err)
+	assert.Contains(string(data), `"timestamp":"2024-01-02T03:04:05.678Z"`)
+
+	batches, err := box.Dequeue(1)
+	assert.NoError(err)
+	assert.Equal(time.Time(stored.Timestamp), time.Time(batches[0].Insights[0].Timestamp))
+}
@@ -0,0 +1,38 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package outbox
+
+import (
+	"time"
+
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+// on disk format of a batch, sequence and attempts are kept in memory only.
+// Times are always stored as RFC 3339.
+type storedBatch struct {
+	CreatedAt     time.Time                 `json:"createdAt"`
+	Insights      []*appliance.Insight      `json:"insights"`
+	Notifications []*appliance.Notification `json:"notifications"`
+}
+
+func newStoredBatch(batch *Batch) *storedBatch {
+	return &storedBatch{
+		CreatedAt:     batch.CreatedAt,
+		Insights:      batch.Insights,
+		Notifications: batch.Notifications,
+	}
+}
+
+func (S *storedBatch) batch() *Batch {
+	return &Batch{
+		CreatedAt:     S.CreatedAt,
+		Insights:      S.Insights,
+		Notifications: S.Notifications,
+	}
+}
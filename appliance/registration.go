@@ -0,0 +1,214 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidTransition = errors.New("invalid registration status transition")
+
+var statusNames = map[Status]string{
+	NotRegistered:      "NOT_REGISTERED",
+	Registering:        "REGISTERING",
+	Registered:         "REGISTERED",
+	RegistrationFailed: "REGISTRATION_FAILED",
+	Deregistering:      "DEREGISTERING",
+}
+
+// allowed registration status changes, staying in the same status is always allowed
+var transitions = map[Status][]Status{
+	NotRegistered:      {Registering},
+	Registering:        {Registered, RegistrationFailed, NotRegistered},
+	RegistrationFailed: {Registering, NotRegistered},
+	Registered:         {Deregistering, NotRegistered},
+	Deregistering:      {NotRegistered, Registered},
+}
+
+func (S Status) String() string {
+	if name, ok := statusNames[S]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", byte(S))
+}
+
+func (S Status) MarshalText() ([]byte, error) {
+	if _, ok := statusNames[S]; !ok {
+		return nil, fmt.Errorf("unknown registration status: %d", byte(S))
+	}
+	return []byte(S.String()), nil
+}
+
+// UnmarshalText accepts status names and the numeric values written by
+// older versions of the sdk
+func (S *Status) UnmarshalText(text []byte) error {
+	value := strings.TrimSpace(string(text))
+	if number, err := strconv.Atoi(value); err == nil {
+		if _, ok := statusNames[Status(number)]; ok {
+			*S = Status(number)
+			return nil
+		}
+	}
+	for status, name := range statusNames {
+		if strings.EqualFold(name, value) {
+			*S = status
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown registration status: %s", value)
+}
+
+func (S Status) CanTransitionTo(to Status) bool {
+	if S == to {
+		return true
+	}
+	for _, allowed := range transitions[S] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError is returned for registration status changes which are not allowed
+type TransitionError struct {
+	From Status
+	To   Status
+}
+
+func (E *TransitionError) Error() string {
+	return fmt.Sprintf("%s: %s -> %s", ErrInvalidTransition, E.From, E.To)
+}
+
+func (E *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+type Transition struct {
+	From   Status
+	To     Status
+	At     time.Time
+	Reason string
+}
+
+func (T *Transition) String() string {
+	return fmt.Sprintf("{from=%s, to=%s, at=%s, reason='%s'}", T.From, T.To, T.At.Format(time.RFC3339), T.Reason)
+}
+
+type TransitionListener func(transition Transition)
+
+// TransitionRegistration validates the status change and records it in the
+// config. reason is kept as failure reason when moving to RegistrationFailed.
+// Staying in the same status is a no-op and returns nil.
+func (C *Config) TransitionRegistration(to Status, reason string, at time.Time) (*Transition, error) {
+	from := C.RegistrationStatus
+	if !from.CanTransitionTo(to) {
+		return nil, &TransitionError{From: from, To: to}
+	}
+	if from == to {
+		return nil, nil
+	}
+
+	C.RegistrationStatus = to
+	C.RegistrationChangedAt = at
+	if to == RegistrationFailed {
+		C.RegistrationFailureReason = reason
+	} else if to == Registered {
+		C.RegistrationFailureReason = ""
+	}
+	return &Transition{From: from, To: to, At: at, Reason: reason}, nil
+}
+
+// RegistrationStateMachine changes the registration status of a config,
+// persists every transition and notifies subscribed listeners
+type RegistrationStateMachine struct {
+	// guards the config, set it when the config is shared with other code
+	Locker sync.Locker
+
+	config *Config
+	dir    string
+
+	mu        sync.Mutex
+	listeners map[int]TransitionListener
+	nextId    int
+}
+
+// NewRegistrationStateMachine returns a state machine for config, transitions
+// are saved to dir unless it is empty
+func NewRegistrationStateMachine(config *Config, dir string) *RegistrationStateMachine {
+	return &RegistrationStateMachine{
+		Locker:    &sync.Mutex{},
+		config:    config,
+		dir:       dir,
+		listeners: map[int]TransitionListener{},
+	}
+}
+
+func (R *RegistrationStateMachine) Status() Status {
+	R.Locker.Lock()
+	defer R.Locker.Unlock()
+	return R.config.RegistrationStatus
+}
+
+// Transition moves the config to status to, reason is recorded for failures
+func (R *RegistrationStateMachine) Transition(to Status, reason string) error {
+	R.Locker.Lock()
+	previous := *R.config
+	transition, err := R.config.TransitionRegistration(to, reason, time.Now().UTC())
+	if err == nil && transition != nil && len(R.dir) > 0 {
+		if err = R.config.Save(R.dir); err != nil {
+			*R.config = previous
+		}
+	}
+	R.Locker.Unlock()
+
+	if err != nil || transition == nil {
+		return err
+	}
+
+	for _, listener := range R.subscribers() {
+		listener(*transition)
+	}
+	return nil
+}
+
+// Subscribe registers listener for transitions, the returned func removes it
+func (R *RegistrationStateMachine) Subscribe(listener TransitionListener) func() {
+	R.mu.Lock()
+	defer R.mu.Unlock()
+	id := R.nextId
+	R.nextId++
+	R.listeners[id] = listener
+	return func() {
+		R.mu.Lock()
+		defer R.mu.Unlock()
+		delete(R.listeners, id)
+	}
+}
+
+func (R *RegistrationStateMachine) subscribers() []TransitionListener {
+	R.mu.Lock()
+	defer R.mu.Unlock()
+	ids := make([]int, 0, len(R.listeners))
+	for id := range R.listeners {
+		ids = append(ids, id)
+	}
+	// notify in subscription order
+	sort.Ints(ids)
+	listeners := make([]TransitionListener, 0, len(ids))
+	for _, id := range ids {
+		listeners = append(listeners, R.listeners[id])
+	}
+	return listeners
+}
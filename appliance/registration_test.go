@@ -0,0 +1,67 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+func TestRegistrationStateMachine(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	config := &appliance.Config{Id: "1", Keys: testKeys(t)}
+	registration := appliance.NewRegistrationStateMachine(config, dir)
+
+	transitions := []appliance.Transition{}
+	unsubscribe := registration.Subscribe(func(transition appliance.Transition) {
+		transitions = append(transitions, transition)
+	})
+
+	assert.ErrorIs(registration.Transition(appliance.Registered, ""), appliance.ErrInvalidTransition)
+	assert.NoError(registration.Transition(appliance.Registering, ""))
+	assert.NoError(registration.Transition(appliance.Registering, ""))
+	assert.NoError(registration.Transition(appliance.RegistrationFailed, "backend unavailable"))
+
+	reloaded := &appliance.Config{Keys: config.Keys}
+	assert.NoError(reloaded.Reload(dir))
+	assert.Equal(appliance.RegistrationFailed, reloaded.RegistrationStatus)
+	assert.Equal("backend unavailable", reloaded.RegistrationFailureReason)
+	assert.False(reloaded.RegistrationChangedAt.IsZero())
+
+	data, err := os.ReadFile(filepath.Join(dir, "config.toml"))
+	assert.NoError(err)
+	assert.Contains(string(data), `registrationStatus = "REGISTRATION_FAILED"`)
+
+	unsubscribe()
+	assert.NoError(registration.Transition(appliance.Registering, ""))
+	assert.Len(transitions, 2)
+	assert.Equal(appliance.NotRegistered, transitions[0].From)
+	assert.Equal(appliance.RegistrationFailed, transitions[1].To)
+	assert.Equal("backend unavailable", transitions[1].Reason)
+}
+
+func TestStatusReadsNumericValues(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	assert.NoError(os.WriteFile(filepath.Join(dir, "config.toml"), []byte("id = \"1\"\nregistrationStatus = 2\n"), 0644))
+
+	config := &appliance.Config{Keys: testKeys(t)}
+	assert.NoError(config.Reload(dir))
+	assert.Equal(appliance.Registered, config.RegistrationStatus)
+	assert.Equal("REGISTERED", config.RegistrationStatus.String())
+
+	var status appliance.Status
+	assert.Error(status.UnmarshalText([]byte("UNKNOWN")))
+	assert.NoError(status.UnmarshalText([]byte("deregistering")))
+	assert.Equal(appliance.Deregistering, status)
+}
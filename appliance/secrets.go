@@ -0,0 +1,177 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Config fields tagged with `secret:"true"` are encrypted by ConfigManager.Save
+// and decrypted by ConfigManager.Unmarshal. Only string fields can be secret,
+// nested structs and pointers to structs are walked as well. Values are bound
+// to the field name and to the context of configs implementing ContextBinder.
+//
+// Fields tagged with `secret:"redact"` are not encrypted, because they are
+// protected elsewhere like the password of ConfigWithPassword. Their values
+// are hidden from config dumps and change reports like those of secret fields.
+const (
+	SecretTag = "secret"
+
+	SecretEncrypt = "true"
+	SecretRedact  = "redact"
+)
+
+// visitSecret is called for every non-empty secret field, name is the dotted
+// path of the field in the config struct
+type visitSecret func(field reflect.Value, name string) error
+
+// sealSecrets returns a copy of config with secret fields encrypted, config
+// itself is left untouched
+func sealSecrets(config interface{}, keys KeyProvider) (interface{}, error) {
+	rv := reflect.ValueOf(config)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return config, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || !hasSecrets(rv.Type(), map[reflect.Type]bool{}) {
+		return config, nil
+	}
+
+	context := contextOf(config)
+	copied := reflect.New(rv.Type())
+	copied.Elem().Set(rv)
+
+	err := walkSecrets(copied.Elem(), "", true, func(field reflect.Value, name string) error {
+		sealed, err := SealEnvelopeWithContext(field.String(), keys, context.WithField(name))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %s", name, err)
+		}
+		field.SetString(sealed)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copied.Interface(), nil
+}
+
+// openSecrets decrypts secret fields of config in place. stale is true when a
+// field holds cleartext or a value which should be re-encrypted.
+func openSecrets(config interface{}, keys KeyProvider) (stale bool, err error) {
+	rv := reflect.ValueOf(config)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return false, nil
+	}
+
+	context := contextOf(config)
+	err = walkSecrets(rv.Elem(), "", false, func(field reflect.Value, name string) error {
+		if !IsEnvelope(field.String()) {
+			// written in cleartext by hand or by an older version
+			stale = true
+			return nil
+		}
+		plaintext, expired, err := OpenEnvelopeWithContext(field.String(), keys, context.WithField(name))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", name, err)
+		}
+		field.SetString(plaintext)
+		stale = stale || expired
+		return nil
+	})
+	return stale, err
+}
+
+// walkSecrets calls visit for secret fields of the struct rv. When detach is
+// set, pointers to structs holding secrets are replaced by copies before they
+// are walked, so the caller's values are never modified.
+func walkSecrets(rv reflect.Value, prefix string, detach bool, visit visitSecret) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		value := rv.Field(i)
+		name := prefix + field.Name
+
+		if isSecret(field) {
+			if value.Kind() != reflect.String {
+				return fmt.Errorf("secret field %s must be a string", name)
+			}
+			if value.Len() > 0 {
+				if err := visit(value, name); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		switch value.Kind() {
+		case reflect.Struct:
+			if err := walkSecrets(value, name+".", detach, visit); err != nil {
+				return err
+			}
+		case reflect.Ptr:
+			if value.IsNil() || value.Elem().Kind() != reflect.Struct {
+				continue
+			}
+			if !hasSecrets(value.Elem().Type(), map[reflect.Type]bool{}) {
+				continue
+			}
+			if detach {
+				copied := reflect.New(value.Elem().Type())
+				copied.Elem().Set(value.Elem())
+				value.Set(copied)
+			}
+			if err := walkSecrets(value.Elem(), name+".", detach, visit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isSecret(field reflect.StructField) bool {
+	return field.Tag.Get(SecretTag) == SecretEncrypt
+}
+
+// isRedacted is true for fields whose values must not be shown
+func isRedacted(field reflect.StructField) bool {
+	value := field.Tag.Get(SecretTag)
+	return value == SecretEncrypt || value == SecretRedact
+}
+
+func hasSecrets(rt reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[rt] {
+		return false
+	}
+	seen[rt] = true
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if isSecret(field) {
+			return true
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && hasSecrets(ft, seen) {
+			return true
+		}
+	}
+	return false
+}
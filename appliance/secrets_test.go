@@ -0,0 +1,67 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+type tokenConfig struct {
+	Token string `toml:"token" secret:"true"`
+}
+
+type customConfig struct {
+	Name   string       `toml:"name"`
+	ApiKey string       `toml:"apiKey" secret:"true"`
+	Nested *tokenConfig `toml:"nested"`
+}
+
+func TestSecretFields(t *testing.T) {
+	assert := assert.New(t)
+	cfgMgr := appliance.NewConfigManager("custom", "toml", t.TempDir())
+	cfgMgr.Keys = appliance.NewMachineKeyProvider("machine-a")
+
+	config := &customConfig{Name: "kerio", ApiKey: "key", Nested: &tokenConfig{Token: "token"}}
+	assert.NoError(cfgMgr.Save(config))
+	assert.Equal("key", config.ApiKey)
+	assert.Equal("token", config.Nested.Token)
+
+	data, err := os.ReadFile(cfgMgr.FullPath())
+	assert.NoError(err)
+	assert.NotContains(string(data), `"key"`)
+	assert.NotContains(string(data), `"token"`)
+	assert.Contains(string(data), `"kerio"`)
+
+	loaded := &customConfig{}
+	assert.NoError(cfgMgr.Unmarshal(loaded))
+	assert.Equal(*config.Nested, *loaded.Nested)
+	assert.Equal("key", loaded.ApiKey)
+}
+
+func TestSecretFieldsMigratedFromCleartext(t *testing.T) {
+	assert := assert.New(t)
+	cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, t.TempDir())
+	cfgMgr.Keys = appliance.NewMachineKeyProvider("machine-a")
+
+	assert.NoError(os.WriteFile(cfgMgr.FullPath(), []byte("id = \"1\"\nprivateKey = \"private\"\n"), 0644))
+
+	loaded := &appliance.Config{}
+	assert.NoError(cfgMgr.LoadApplianceConfig(loaded))
+	assert.Equal("private", loaded.PrivateKey)
+
+	stored := struct {
+		PrivateKey string `toml:"privateKey"`
+	}{}
+	assert.NoError(appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, cfgMgr.Path).Unmarshal(&stored))
+	assert.True(strings.HasPrefix(stored.PrivateKey, appliance.EnvelopePrefix))
+}
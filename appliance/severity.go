@@ -0,0 +1,102 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Severity of a notification, severities are ordered from Info to Critical
+type Severity string
+
+const (
+	SeverityInfo     Severity = "Info"
+	SeverityWarning  Severity = "Warning"
+	SeverityError    Severity = "Error"
+	SeverityCritical Severity = "Critical"
+)
+
+var ErrUnknownSeverity = errors.New("unknown severity")
+
+// known severities from lowest to highest
+var severities = []Severity{SeverityInfo, SeverityWarning, SeverityError, SeverityCritical}
+
+// spellings accepted by ParseSeverity besides the names of the severities
+var severityAliases = map[string]Severity{
+	"information": SeverityInfo,
+	"warn":        SeverityWarning,
+	"err":         SeverityError,
+	"crit":        SeverityCritical,
+	"fatal":       SeverityCritical,
+}
+
+// ParseSeverity accepts the severity names and common abbreviations, ignoring case
+func ParseSeverity(value string) (Severity, error) {
+	value = strings.TrimSpace(value)
+	for _, severity := range severities {
+		if strings.EqualFold(string(severity), value) {
+			return severity, nil
+		}
+	}
+	if severity, ok := severityAliases[strings.ToLower(value)]; ok {
+		return severity, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownSeverity, value)
+}
+
+func (S Severity) IsValid() bool {
+	return S.Rank() >= 0
+}
+
+// Rank returns the position of the severity from 0 for Info, -1 if unknown
+func (S Severity) Rank() int {
+	for i, severity := range severities {
+		if S == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// Compare returns -1, 0 or 1 when S is lower, equal or higher than other,
+// unknown severities are lower than all known ones
+func (S Severity) Compare(other Severity) int {
+	a, b := S.Rank(), other.Rank()
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (S Severity) String() string {
+	return string(S)
+}
+
+// MarshalText writes the severity as is, unknown severities are only
+// rejected by the notification builder
+func (S Severity) MarshalText() ([]byte, error) {
+	return []byte(S), nil
+}
+
+// UnmarshalText normalizes the spellings accepted by ParseSeverity and keeps
+// unknown severities unchanged
+func (S *Severity) UnmarshalText(text []byte) error {
+	severity, err := ParseSeverity(string(text))
+	if err != nil {
+		*S = Severity(text)
+		return nil
+	}
+	*S = severity
+	return nil
+}
@@ -0,0 +1,58 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+func TestParseSeverity(t *testing.T) {
+	assert := assert.New(t)
+	for value, expected := range map[string]appliance.Severity{
+		"Info":     appliance.SeverityInfo,
+		"warn":     appliance.SeverityWarning,
+		"WARNING ": appliance.SeverityWarning,
+		"error":    appliance.SeverityError,
+		"fatal":    appliance.SeverityCritical,
+	} {
+		severity, err := appliance.ParseSeverity(value)
+		assert.NoError(err)
+		assert.Equal(expected, severity)
+	}
+	_, err := appliance.ParseSeverity("warnings")
+	assert.ErrorIs(err, appliance.ErrUnknownSeverity)
+
+	assert.Equal(1, appliance.SeverityCritical.Compare(appliance.SeverityError))
+	assert.Equal(-1, appliance.Severity("bogus").Compare(appliance.SeverityInfo))
+}
+
+func TestSeverityMarshalling(t *testing.T) {
+	assert := assert.New(t)
+	notification := &appliance.Notification{}
+	assert.NoError(json.Unmarshal([]byte(`{"severity": "warn"}`), notification))
+	assert.Equal(appliance.SeverityWarning, notification.Severity)
+	assert.NoError(json.Unmarshal([]byte(`{"severity": "High"}`), notification))
+	assert.Equal(appliance.Severity("High"), notification.Severity)
+
+	data, err := json.Marshal(&appliance.Notification{Severity: "High"})
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(data, notification))
+	assert.Equal(appliance.Severity("High"), notification.Severity)
+
+	var config struct {
+		Threshold appliance.Severity `toml:"threshold"`
+	}
+	_, err = toml.Decode(`threshold = "CRITICAL"`, &config)
+	assert.NoError(err)
+	assert.Equal(appliance.SeverityCritical, config.Threshold)
+}
@@ -7,13 +7,115 @@
 
 package types
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
 var Types = []Type{}
 
+var mutex sync.RWMutex
+
+var (
+	ErrTypeExists   = errors.New("appliance type already registered")
+	ErrTypeNotFound = errors.New("appliance type not found")
+)
+
 type Type interface {
 	Name() string
 	Synonyms() []string
 }
 
-func Register(type_ Type) {
+// Factory is implemented by types which can create their appliances
+type Factory interface {
+	Type
+	New(cfg *appliance.Config) (appliance.Appliance, error)
+}
+
+// Register adds type_ to Types, its name and synonyms must not be claimed by
+// an already registered type
+func Register(type_ Type) error {
+	if len(type_.Name()) == 0 {
+		return errors.New("appliance type name is empty")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, name := range names(type_) {
+		if existing := lookup(name); existing != nil {
+			return fmt.Errorf("%w: %s is claimed by %s", ErrTypeExists, name, existing.Name())
+		}
+	}
 	Types = append(Types, type_)
+	return nil
+}
+
+// MustRegister is like Register but panics on error, meant for init functions
+func MustRegister(type_ Type) {
+	if err := Register(type_); err != nil {
+		panic(err)
+	}
+}
+
+// Unregister removes the type registered under name or one of its synonyms
+func Unregister(name string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for i, type_ := range Types {
+		for _, existing := range names(type_) {
+			if strings.EqualFold(existing, name) {
+				Types = append(Types[:i:i], Types[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Lookup finds a registered type by its name or one of its synonyms, ignoring case
+func Lookup(name string) (Type, bool) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	type_ := lookup(name)
+	return type_, type_ != nil
+}
+
+// Registered returns a copy of Types which is safe to range over while
+// types are being registered
+func Registered() []Type {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return append([]Type{}, Types...)
+}
+
+// NewAppliance creates an appliance of the type named in cfg, it can be used
+// as appliance.ApplianceFactory of an appliance.Manager
+func NewAppliance(cfg *appliance.Config) (appliance.Appliance, error) {
+	type_, ok := Lookup(cfg.Type)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTypeNotFound, cfg.Type)
+	}
+	factory, ok := type_.(Factory)
+	if !ok {
+		return nil, fmt.Errorf("appliance type %s has no factory", type_.Name())
+	}
+	return factory.New(cfg)
+}
+
+func lookup(name string) Type {
+	for _, type_ := range Types {
+		for _, existing := range names(type_) {
+			if strings.EqualFold(existing, name) {
+				return type_
+			}
+		}
+	}
+	return nil
+}
+
+func names(type_ Type) []string {
+	return append([]string{type_.Name()}, type_.Synonyms()...)
 }
@@ -0,0 +1,81 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package types_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance/types"
+)
+
+type testType struct {
+	name     string
+	synonyms []string
+	created  *appliance.Config
+}
+
+func (T *testType) Name() string       { return T.name }
+func (T *testType) Synonyms() []string { return T.synonyms }
+
+func (T *testType) New(cfg *appliance.Config) (appliance.Appliance, error) {
+	T.created = cfg
+	return nil, errors.New("not implemented")
+}
+
+// type without a factory
+type plainType struct{ name string }
+
+func (P *plainType) Name() string       { return P.name }
+func (P *plainType) Synonyms() []string { return nil }
+
+func TestRegisterAndLookup(t *testing.T) {
+	assert := assert.New(t)
+	connect := &testType{name: "Kerio-Connect", synonyms: []string{"KerioConnect", "connect"}}
+	assert.NoError(types.Register(connect))
+	t.Cleanup(func() { types.Unregister("Kerio-Connect") })
+
+	found, ok := types.Lookup("kerio-connect")
+	assert.True(ok)
+	assert.Same(connect, found)
+	found, ok = types.Lookup("CONNECT")
+	assert.True(ok)
+	assert.Same(connect, found)
+	_, ok = types.Lookup("Kerio-Control")
+	assert.False(ok)
+
+	assert.ErrorIs(types.Register(&testType{name: "Kerio-Connect"}), types.ErrTypeExists)
+	assert.ErrorIs(types.Register(&testType{name: "Other", synonyms: []string{"kerioconnect"}}), types.ErrTypeExists)
+	assert.Error(types.Register(&testType{}))
+	assert.Panics(func() { types.MustRegister(&testType{name: "connect"}) })
+
+	types.Unregister("connect")
+	_, ok = types.Lookup("Kerio-Connect")
+	assert.False(ok)
+}
+
+func TestNewAppliance(t *testing.T) {
+	assert := assert.New(t)
+	control := &testType{name: "Kerio-Control"}
+	assert.NoError(types.Register(control))
+	t.Cleanup(func() { types.Unregister("Kerio-Control") })
+	assert.NoError(types.Register(&plainType{name: "Languard"}))
+	t.Cleanup(func() { types.Unregister("Languard") })
+
+	cfg := &appliance.Config{Id: "1", Type: "kerio-control"}
+	_, err := types.NewAppliance(cfg)
+	assert.EqualError(err, "not implemented")
+	assert.Same(cfg, control.created)
+
+	_, err = types.NewAppliance(&appliance.Config{Type: "Exinda"})
+	assert.ErrorIs(err, types.ErrTypeNotFound)
+	_, err = types.NewAppliance(&appliance.Config{Type: "Languard"})
+	assert.ErrorContains(err, "has no factory")
+}
@@ -0,0 +1,206 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+// kinds of quantities, units convert only within the same kind
+const (
+	UnitKindData     = "data"
+	UnitKindDataRate = "data rate"
+	UnitKindTime     = "time"
+	UnitKindCount    = "count"
+	UnitKindRate     = "rate"
+	UnitKindPercent  = "percent"
+	UnitKindNone     = "none"
+)
+
+var (
+	ErrUnknownUnit       = errors.New("unknown unit")
+	ErrIncompatibleUnits = errors.New("incompatible units")
+)
+
+// Unit is a metric unit, Name is the canonical CloudWatch style name used in
+// Metric.Unit and Factor converts values to the base unit of the kind
+type Unit struct {
+	Name    string
+	Kind    string
+	Factor  float64
+	Aliases []string
+}
+
+var units = struct {
+	sync.RWMutex
+	byName map[string]*Unit
+}{byName: map[string]*Unit{}}
+
+func init() {
+	// data units are counted in bits so bytes and bits convert into each other.
+	// Bits use decimal prefixes and bytes binary ones, KB and friends are not
+	// registered as they are used for both.
+	data := []struct {
+		name        string
+		factor      float64
+		aliases     []string
+		rateAliases []string
+	}{
+		{"Bits", 1, []string{"bit"}, []string{"bit/s", "bps"}},
+		{"Kilobits", 1e3, []string{"kbit"}, []string{"kbit/s", "kbps"}},
+		{"Megabits", 1e6, []string{"Mbit"}, []string{"Mbit/s", "Mbps"}},
+		{"Gigabits", 1e9, []string{"Gbit"}, []string{"Gbit/s", "Gbps"}},
+		{"Terabits", 1e12, []string{"Tbit"}, []string{"Tbit/s", "Tbps"}},
+		{"Bytes", 8, []string{"byte", "B"}, []string{"B/s"}},
+		{"Kilobytes", 8 * 1024, []string{"kilobyte", "KiB"}, []string{"KiB/s"}},
+		{"Megabytes", 8 * 1024 * 1024, []string{"megabyte", "MiB"}, []string{"MiB/s"}},
+		{"Gigabytes", 8 * 1024 * 1024 * 1024, []string{"gigabyte", "GiB"}, []string{"GiB/s"}},
+		{"Terabytes", 8 * 1024 * 1024 * 1024 * 1024, []string{"terabyte", "TiB"}, []string{"TiB/s"}},
+	}
+	for _, unit := range data {
+		mustRegisterUnit(Unit{Name: unit.name, Kind: UnitKindData, Factor: unit.factor, Aliases: unit.aliases})
+		mustRegisterUnit(Unit{Name: unit.name + "/Second", Kind: UnitKindDataRate, Factor: unit.factor, Aliases: unit.rateAliases})
+	}
+
+	mustRegisterUnit(Unit{Name: "Microseconds", Kind: UnitKindTime, Factor: 1e-6, Aliases: []string{"microsecond", "us", "µs"}})
+	mustRegisterUnit(Unit{Name: "Milliseconds", Kind: UnitKindTime, Factor: 1e-3, Aliases: []string{"millisecond", "ms"}})
+	mustRegisterUnit(Unit{Name: "Seconds", Kind: UnitKindTime, Factor: 1, Aliases: []string{"second", "sec", "s"}})
+	mustRegisterUnit(Unit{Name: "Count", Kind: UnitKindCount, Factor: 1, Aliases: []string{"1", "#"}})
+	mustRegisterUnit(Unit{Name: "Count/Second", Kind: UnitKindRate, Factor: 1, Aliases: []string{"1/s", "/s", "per second"}})
+	mustRegisterUnit(Unit{Name: "Percent", Kind: UnitKindPercent, Factor: 1, Aliases: []string{"%", "pct"}})
+	mustRegisterUnit(Unit{Name: "None", Kind: UnitKindNone, Factor: 1, Aliases: []string{""}})
+}
+
+// RegisterUnit adds unit to the registry, its name and aliases are matched
+// ignoring case and must not be claimed by another unit
+func RegisterUnit(unit Unit) error {
+	if len(unit.Name) == 0 || len(unit.Kind) == 0 || unit.Factor <= 0 {
+		return errors.New("invalid unit: name, kind and a positive factor are required")
+	}
+
+	units.Lock()
+	defer units.Unlock()
+	for _, name := range append([]string{unit.Name}, unit.Aliases...) {
+		for existing := range units.byName {
+			if strings.EqualFold(existing, name) {
+				return fmt.Errorf("unit %s is already registered", name)
+			}
+		}
+	}
+	registered := unit
+	for _, name := range append([]string{unit.Name}, unit.Aliases...) {
+		units.byName[name] = &registered
+	}
+	return nil
+}
+
+func mustRegisterUnit(unit Unit) {
+	if err := RegisterUnit(unit); err != nil {
+		panic(err)
+	}
+}
+
+// LookupUnit finds a unit by its name or an alias
+func LookupUnit(name string) (Unit, bool) {
+	name = strings.TrimSpace(name)
+	units.RLock()
+	defer units.RUnlock()
+	if unit, ok := units.byName[name]; ok {
+		return *unit, true
+	}
+	for alias, unit := range units.byName {
+		if strings.EqualFold(alias, name) {
+			return *unit, true
+		}
+	}
+	return Unit{}, false
+}
+
+// CanonicalUnit returns the canonical name of unit
+func CanonicalUnit(name string) (string, error) {
+	unit, ok := LookupUnit(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownUnit, name)
+	}
+	return unit.Name, nil
+}
+
+// ConvertUnit converts value from one unit to another of the same kind
+func ConvertUnit(value float64, from string, to string) (float64, error) {
+	fromUnit, ok := LookupUnit(from)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownUnit, from)
+	}
+	toUnit, ok := LookupUnit(to)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownUnit, to)
+	}
+	if fromUnit.Kind != toUnit.Kind {
+		return 0, fmt.Errorf("%w: %s (%s) and %s (%s)", ErrIncompatibleUnits, fromUnit.Name, fromUnit.Kind, toUnit.Name, toUnit.Kind)
+	}
+	if fromUnit.Name == toUnit.Name {
+		return value, nil
+	}
+	return value * fromUnit.Factor / toUnit.Factor, nil
+}
+
+// ConvertTo returns a copy of the metric in unit, named by its canonical name
+func (M *Metric) ConvertTo(unit string) (*Metric, error) {
+	value, err := ConvertUnit(M.Value, M.Unit, unit)
+	if err != nil {
+		return nil, err
+	}
+	canonical, _ := CanonicalUnit(unit)
+	return &Metric{Unit: canonical, Value: value}, nil
+}
+
+// Normalize replaces an alias of the unit by its canonical name
+func (M *Metric) Normalize() error {
+	canonical, err := CanonicalUnit(M.Unit)
+	if err != nil {
+		return err
+	}
+	M.Unit = canonical
+	return nil
+}
+
+// ValidateInsight checks that an insight can be published: it has a name, a
+// metric with a finite value and a known unit
+func ValidateInsight(insight *Insight) error {
+	if insight == nil {
+		return errors.New("insight is nil")
+	}
+	if len(insight.Name) == 0 {
+		return errors.New("insight name is empty")
+	}
+	if insight.Metric == nil {
+		return fmt.Errorf("insight %s has no metric", insight.Name)
+	}
+	if math.IsNaN(insight.Metric.Value) || math.IsInf(insight.Metric.Value, 0) {
+		return fmt.Errorf("insight %s has invalid value %v", insight.Name, insight.Metric.Value)
+	}
+	if _, ok := LookupUnit(insight.Metric.Unit); !ok {
+		return fmt.Errorf("insight %s: %w: %s", insight.Name, ErrUnknownUnit, insight.Metric.Unit)
+	}
+	return nil
+}
+
+// ValidateInsights validates all insights and joins the errors
+func ValidateInsights(insights []*Insight) error {
+	errs := []error{}
+	for _, insight := range insights {
+		if err := ValidateInsight(insight); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,93 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+func TestCanonicalUnit(t *testing.T) {
+	assert := assert.New(t)
+	for name, expected := range map[string]string{
+		"MiB":          "Megabytes",
+		"megabytes":    "Megabytes",
+		"Mbps":         "Megabits/Second",
+		" ms ":         "Milliseconds",
+		"%":            "Percent",
+		"":             "None",
+		"Count/Second": "Count/Second",
+	} {
+		canonical, err := appliance.CanonicalUnit(name)
+		assert.NoError(err)
+		assert.Equal(expected, canonical)
+	}
+	_, err := appliance.CanonicalUnit("Widgets")
+	assert.ErrorIs(err, appliance.ErrUnknownUnit)
+	// ambiguous between decimal and binary prefixes
+	_, err = appliance.CanonicalUnit("MB")
+	assert.ErrorIs(err, appliance.ErrUnknownUnit)
+
+	assert.Error(appliance.RegisterUnit(appliance.Unit{Name: "mib", Kind: appliance.UnitKindData, Factor: 1}))
+	assert.Error(appliance.RegisterUnit(appliance.Unit{Name: "Widgets", Kind: appliance.UnitKindCount}))
+}
+
+func TestConvertUnit(t *testing.T) {
+	assert := assert.New(t)
+	value, err := appliance.ConvertUnit(2, "MiB", "Bytes")
+	assert.NoError(err)
+	assert.Equal(float64(2*1024*1024), value)
+
+	value, err = appliance.ConvertUnit(2, "Mbps", "bps")
+	assert.NoError(err)
+	assert.Equal(float64(2000000), value)
+
+	value, err = appliance.ConvertUnit(1, "Kilobytes", "Kilobits")
+	assert.NoError(err)
+	assert.Equal(8.192, value)
+
+	value, err = appliance.ConvertUnit(3, "Bytes", "Bits")
+	assert.NoError(err)
+	assert.Equal(float64(24), value)
+
+	value, err = appliance.ConvertUnit(1500, "ms", "Seconds")
+	assert.NoError(err)
+	assert.Equal(1.5, value)
+
+	_, err = appliance.ConvertUnit(1, "Seconds", "Bytes")
+	assert.ErrorIs(err, appliance.ErrIncompatibleUnits)
+	_, err = appliance.ConvertUnit(1, "Widgets", "Count")
+	assert.ErrorIs(err, appliance.ErrUnknownUnit)
+
+	metric := &appliance.Metric{Unit: "KiB", Value: 2048}
+	converted, err := metric.ConvertTo("mib")
+	assert.NoError(err)
+	assert.Equal(&appliance.Metric{Unit: "Megabytes", Value: 2}, converted)
+	assert.Equal("KiB", metric.Unit)
+	assert.NoError(metric.Normalize())
+	assert.Equal("Kilobytes", metric.Unit)
+}
+
+func TestValidateInsights(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError(appliance.ValidateInsights([]*appliance.Insight{{Name: "a", Metric: &appliance.Metric{Unit: "ms", Value: 1}}}))
+
+	err := appliance.ValidateInsights([]*appliance.Insight{
+		{Name: "a", Metric: &appliance.Metric{Unit: "Count", Value: 1}},
+		{Name: "b"},
+		{Name: "c", Metric: &appliance.Metric{Unit: "Count", Value: math.NaN()}},
+		{Name: "d", Metric: &appliance.Metric{Unit: "Widgets", Value: 1}},
+	})
+	assert.ErrorIs(err, appliance.ErrUnknownUnit)
+	assert.Contains(err.Error(), "insight b has no metric")
+	assert.Contains(err.Error(), "insight c has invalid value NaN")
+	assert.NotContains(err.Error(), "insight a")
+}
@@ -0,0 +1,249 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/trilogy-group/gfi-agent-sdk/logger"
+)
+
+const (
+	// quiet period after the last write before a changed config is reloaded
+	DefaultWatchDebounce = 500 * time.Millisecond
+
+	// interval of the polling watcher used where file notifications are not available
+	DefaultWatchPollInterval = 2 * time.Second
+)
+
+// ConfigChange is a top level config value which differs between two loads
+type ConfigChange struct {
+	Field string
+	Key   string
+	Old   interface{}
+	New   interface{}
+}
+
+// WatchFunc receives the previous and the reloaded config along with the
+// values that changed, both configs have the type passed to Watch
+type WatchFunc func(old, new interface{}, changes []ConfigChange)
+
+// hashes of the content last written by Save, keyed by path, so watchers can
+// ignore changes made by the agent itself
+var selfWrites = struct {
+	sync.Mutex
+	hashes map[string][32]byte
+}{hashes: map[string][32]byte{}}
+
+func recordSelfWrite(path string, data []byte) {
+	selfWrites.Lock()
+	defer selfWrites.Unlock()
+	selfWrites.hashes[path] = sha256.Sum256(data)
+}
+
+func isSelfWrite(path string, hash [32]byte) bool {
+	selfWrites.Lock()
+	defer selfWrites.Unlock()
+	return selfWrites.hashes[path] == hash
+}
+
+// Watch reloads the config whenever its file changes and calls onChange with
+// the differences. config is the currently loaded config, it is passed as old
+// on the first change. Configs are reloaded like LoadApplianceConfig does and
+// fields which are not persisted, like Config.Dir, are kept. Values of secret
+// fields are redacted in the changes. Rapid writes are debounced and changes
+// written by Save of this process are ignored. Watch blocks until ctx is done.
+func (C *ConfigManager) Watch(ctx context.Context, config interface{}, onChange WatchFunc) error {
+	rv := reflect.ValueOf(config)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("watched config must be a pointer to a struct")
+	}
+
+	path := C.FullPath()
+	events, err := watchFile(ctx, path)
+	if err != nil {
+		logger.Logger.Infof("Falling back to polling config %s: %s", path, err)
+		events = pollFile(ctx, path, C.watchPollInterval())
+	}
+
+	current := config
+	lastHash := fileHash(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-events:
+		}
+
+		if !debounce(ctx, events, C.watchDebounce()) {
+			return ctx.Err()
+		}
+
+		hash := fileHash(path)
+		if hash == lastHash {
+			continue
+		}
+		lastHash = hash
+
+		next := reflect.New(rv.Elem().Type()).Interface()
+		if err := C.reload(next); err != nil {
+			logger.Logger.Warningf("Failed to reload changed config %s: %s", path, err)
+			continue
+		}
+		keepUnpersisted(current, next, C.Type)
+
+		if isSelfWrite(path, hash) {
+			current = next
+			continue
+		}
+
+		changes := diffConfigs(current, next, C.Type)
+		if len(changes) > 0 {
+			onChange(current, next, changes)
+		}
+		current = next
+	}
+}
+
+// reload loads config the way it is loaded by the agent, decrypting the
+// password of ConfigWithPassword
+func (C *ConfigManager) reload(config interface{}) error {
+	if _, ok := config.(ConfigWithPassword); ok {
+		return C.LoadApplianceConfig(config)
+	}
+	return C.Unmarshal(config)
+}
+
+// keepUnpersisted copies the fields which are not stored in config files of
+// configType from old to new
+func keepUnpersisted(old, new interface{}, configType string) {
+	oldValue := reflect.ValueOf(old).Elem()
+	newValue := reflect.ValueOf(new).Elem()
+	rt := oldValue.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.IsExported() && fieldKey(field, configType) == "-" {
+			newValue.Field(i).Set(oldValue.Field(i))
+		}
+	}
+}
+
+func (C *ConfigManager) watchDebounce() time.Duration {
+	if C.WatchDebounce <= 0 {
+		return DefaultWatchDebounce
+	}
+	return C.WatchDebounce
+}
+
+func (C *ConfigManager) watchPollInterval() time.Duration {
+	if C.WatchPollInterval <= 0 {
+		return DefaultWatchPollInterval
+	}
+	return C.WatchPollInterval
+}
+
+// debounce waits until no event arrives for quiet, false when ctx is done
+func debounce(ctx context.Context, events <-chan struct{}, quiet time.Duration) bool {
+	timer := time.NewTimer(quiet)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-events:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(quiet)
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// pollFile reports changes of size or modification time of path
+func pollFile(ctx context.Context, path string, interval time.Duration) <-chan struct{} {
+	events := make(chan struct{}, 1)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last, _ := os.Stat(path)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			info, _ := os.Stat(path)
+			if !sameFileInfo(last, info) {
+				notify(events)
+			}
+			last = info
+		}
+	}()
+	return events
+}
+
+func sameFileInfo(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+// notify sends an event without blocking, a pending event already covers it
+func notify(events chan struct{}) {
+	select {
+	case events <- struct{}{}:
+	default:
+	}
+}
+
+func fileHash(path string) [32]byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [32]byte{}
+	}
+	return sha256.Sum256(data)
+}
+
+// diffConfigs compares the persisted fields of two configs
+func diffConfigs(old, new interface{}, configType string) []ConfigChange {
+	oldValue := reflect.ValueOf(old).Elem()
+	newValue := reflect.ValueOf(new).Elem()
+	rt := oldValue.Type()
+
+	changes := []ConfigChange{}
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() || fieldKey(field, configType) == "-" {
+			continue
+		}
+		before := oldValue.Field(i).Interface()
+		after := newValue.Field(i).Interface()
+		if reflect.DeepEqual(before, after) {
+			continue
+		}
+		if isRedacted(field) {
+			before, after = redactedValue, redactedValue
+		}
+		changes = append(changes, ConfigChange{
+			Field: field.Name,
+			Key:   fieldKey(field, configType),
+			Old:   before,
+			New:   after,
+		})
+	}
+	return changes
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+func watchFile(ctx context.Context, path string) (<-chan struct{}, error) {
+	return nil, fmt.Errorf("file notifications not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,126 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package appliance_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+)
+
+func TestWatch(t *testing.T) {
+	assert := assert.New(t)
+	cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, t.TempDir())
+	cfgMgr.Keys = appliance.NewMachineKeyProvider("machine-a")
+	cfgMgr.WatchDebounce = 50 * time.Millisecond
+	config := &appliance.Config{Id: "1", ServerDir: "/opt/first"}
+	assert.NoError(cfgMgr.Save(config))
+
+	type event struct {
+		old, new *appliance.Config
+		changes  []appliance.ConfigChange
+	}
+	events := make(chan event, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	done := make(chan error, 1)
+	go func() {
+		done <- cfgMgr.Watch(ctx, config, func(old, new interface{}, changes []appliance.ConfigChange) {
+			events <- event{old.(*appliance.Config), new.(*appliance.Config), changes}
+		})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// changes saved by the agent itself are not reported
+	assert.NoError(cfgMgr.Save(&appliance.Config{Id: "1", ServerDir: "/opt/second"}))
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected change %v", e.changes)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// rapid writes by other processes are reported once
+	for _, dir := range []string{"/opt/third", "/opt/fourth"} {
+		assert.NoError(os.WriteFile(cfgMgr.FullPath(), []byte("id = \"1\"\nserverDir = \""+dir+"\"\n"), 0644))
+	}
+	select {
+	case e := <-events:
+		assert.Equal("/opt/second", e.old.ServerDir)
+		assert.Equal("/opt/fourth", e.new.ServerDir)
+		assert.Equal([]appliance.ConfigChange{{Field: "ServerDir", Key: "serverDir", Old: "/opt/second", New: "/opt/fourth"}}, e.changes)
+	case <-time.After(3 * time.Second):
+		t.Fatal("change not reported")
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected change %v", e.changes)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	cancel()
+	assert.ErrorIs(<-done, context.Canceled)
+}
+
+func TestWatchKeepsPasswordAndDir(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	keys := appliance.NewMachineKeyProvider("machine-a")
+	assert.NoError((&appliance.Config{Id: "1", Password: "pw", ServerDir: "/opt/first", Keys: keys}).Save(dir))
+	config := &appliance.Config{Dir: dir, Keys: keys}
+	assert.NoError(config.Reload(dir))
+
+	cfgMgr := appliance.NewConfigManager(appliance.ApplianceConfigName, appliance.ApplianceConfigType, dir)
+	cfgMgr.Keys = keys
+	cfgMgr.WatchDebounce = 50 * time.Millisecond
+	events := make(chan *appliance.Config, 10)
+	changes := make(chan []appliance.ConfigChange, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	done := make(chan error, 1)
+	go func() {
+		done <- cfgMgr.Watch(ctx, config, func(old, new interface{}, c []appliance.ConfigChange) {
+			events <- new.(*appliance.Config)
+			changes <- c
+		})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// an external edit leaving the password alone
+	data, err := os.ReadFile(cfgMgr.FullPath())
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(cfgMgr.FullPath(), []byte(strings.Replace(string(data), "/opt/first", "/opt/second", 1)), 0644))
+	select {
+	case next := <-events:
+		assert.Equal("pw", next.Password)
+		assert.Equal(dir, next.Dir)
+		assert.Equal(keys, next.Keys)
+		assert.Equal([]appliance.ConfigChange{{Field: "ServerDir", Key: "serverDir", Old: "/opt/first", New: "/opt/second"}}, <-changes)
+	case <-time.After(3 * time.Second):
+		t.Fatal("change not reported")
+	}
+
+	// password changes are reported without their values
+	data, err = os.ReadFile(cfgMgr.FullPath())
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(cfgMgr.FullPath(), []byte(strings.Replace(string(data), `password = ""`, `password = "changed"`, 1)), 0644))
+	select {
+	case next := <-events:
+		assert.Equal("changed", next.Password)
+		assert.Contains(<-changes, appliance.ConfigChange{Field: "Password", Key: "password", Old: "<redacted>", New: "<redacted>"})
+	case <-time.After(3 * time.Second):
+		t.Fatal("change not reported")
+	}
+
+	cancel()
+	assert.ErrorIs(<-done, context.Canceled)
+}
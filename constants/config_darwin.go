@@ -17,4 +17,9 @@ const (
 	KerioConnectServerConfigPath = "mailserver/mailserver.cfg"
 	GFILanguardAPIConfigPath     = "/restapi.cfg"
 	KerioControlServerConfigPath = "/winroute.cfg"
+
+	// default install roots, empty when the product does not run on this platform
+	KerioConnectInstallDir = "/usr/local/kerio"
+	KerioControlInstallDir = ""
+	GFILanguardInstallDir  = ""
 )
@@ -17,4 +17,9 @@ const (
 	KerioConnectServerConfigPath = "mailserver/mailserver.cfg"
 	GFILanguardAPIConfigPath     = "/restapi.cfg"
 	KerioControlServerConfigPath = "/winroute.cfg"
-)
\ No newline at end of file
+
+	// default install roots, empty when the product does not run on this platform
+	KerioConnectInstallDir = "/opt/kerio"
+	KerioControlInstallDir = "/opt/kerio/winroute"
+	GFILanguardInstallDir  = ""
+)
@@ -17,4 +17,9 @@ const (
 	KerioConnectServerConfigPath = "MailServer\\mailserver.cfg"
 	GFILanguardAPIConfigPath     = "\\restapi.cfg"
 	KerioControlServerConfigPath = "\\winroute.cfg"
+
+	// default install roots, empty when the product does not run on this platform
+	KerioConnectInstallDir = "C:\\Program Files\\Kerio"
+	KerioControlInstallDir = "C:\\Program Files\\Kerio\\WinRoute Firewall"
+	GFILanguardInstallDir  = "C:\\Program Files (x86)\\GFI\\LanGuard 12"
 )
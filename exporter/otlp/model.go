@@ -0,0 +1,239 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package otlp
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/version"
+)
+
+// resource attributes describing the appliance
+const (
+	AttributeServiceName      = "service.name"
+	AttributeServiceVersion   = "service.version"
+	AttributeApplianceId      = "gfi.appliance.id"
+	AttributeApplianceType    = "gfi.appliance.type"
+	AttributeApplianceVersion = "gfi.appliance.version"
+
+	ServiceName = "gfi-agent"
+)
+
+// OTLP severity numbers of the notification severities
+var severityNumbers = map[appliance.Severity]int{
+	appliance.SeverityInfo:     9,
+	appliance.SeverityWarning:  13,
+	appliance.SeverityError:    17,
+	appliance.SeverityCritical: 21,
+}
+
+// UCUM units of the canonical insight units, bits of the registry are decimal
+// multiples and bytes binary ones. Rates of the data units are added by init.
+var ucumUnits = map[string]string{
+	"Seconds":      "s",
+	"Milliseconds": "ms",
+	"Microseconds": "us",
+	"Bits":         "bit",
+	"Kilobits":     "kbit",
+	"Megabits":     "Mbit",
+	"Gigabits":     "Gbit",
+	"Terabits":     "Tbit",
+	"Bytes":        "By",
+	"Kilobytes":    "KiBy",
+	"Megabytes":    "MiBy",
+	"Gigabytes":    "GiBy",
+	"Terabytes":    "TiBy",
+	"Percent":      "%",
+	"Count":        "1",
+	"None":         "1",
+	"Count/Second": "1/s",
+}
+
+func init() {
+	rates := map[string]string{}
+	for name, ucum := range ucumUnits {
+		if unit, ok := appliance.LookupUnit(name); ok && unit.Kind == appliance.UnitKindData {
+			rates[name+"/Second"] = ucum + "/s"
+		}
+	}
+	for name, ucum := range rates {
+		ucumUnits[name] = ucum
+	}
+}
+
+// OTLP/HTTP JSON messages, only the fields written by the exporter
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type exportMetricsRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type metric struct {
+	Name  string `json:"name"`
+	Unit  string `json:"unit,omitempty"`
+	Gauge gauge  `json:"gauge"`
+}
+
+type gauge struct {
+	DataPoints []dataPoint `json:"dataPoints"`
+}
+
+type dataPoint struct {
+	Attributes   []keyValue `json:"attributes,omitempty"`
+	TimeUnixNano string     `json:"timeUnixNano"`
+	AsDouble     float64    `json:"asDouble"`
+}
+
+type exportLogsRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []logRecord `json:"logRecords"`
+}
+
+type logRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber,omitempty"`
+	SeverityText         string     `json:"severityText,omitempty"`
+	Body                 anyValue   `json:"body"`
+	Attributes           []keyValue `json:"attributes,omitempty"`
+}
+
+func metricsRequest(info *appliance.ApplianceInfo, insights []*appliance.Insight) *exportMetricsRequest {
+	now := time.Now()
+	metrics := []metric{}
+	for _, insight := range insights {
+		unit := insight.Metric.Unit
+		if canonical, err := appliance.CanonicalUnit(unit); err == nil {
+			unit = canonical
+		}
+		if ucum, ok := ucumUnits[unit]; ok {
+			unit = ucum
+		}
+		metrics = append(metrics, metric{
+			Name: insight.Name,
+			Unit: unit,
+			Gauge: gauge{DataPoints: []dataPoint{{
+				Attributes:   dimensionAttributes(insight.Dimensions),
+				TimeUnixNano: unixNano(time.Time(insight.Timestamp), now),
+				AsDouble:     insight.Metric.Value,
+			}}},
+		})
+	}
+	return &exportMetricsRequest{ResourceMetrics: []resourceMetrics{{
+		Resource:     resourceOf(info),
+		ScopeMetrics: []scopeMetrics{{Scope: scopeOf(), Metrics: metrics}},
+	}}}
+}
+
+func logsRequest(info *appliance.ApplianceInfo, notifications []*appliance.Notification) *exportLogsRequest {
+	now := time.Now()
+	records := []logRecord{}
+	for _, notification := range notifications {
+		attributes := []keyValue{
+			attribute("notification.type", notification.Type),
+			attribute("notification.name", notification.Name),
+		}
+		records = append(records, logRecord{
+			TimeUnixNano:         unixNano(time.Time(notification.Timestamp), now),
+			ObservedTimeUnixNano: unixNano(now, now),
+			SeverityNumber:       severityNumbers[notification.Severity],
+			SeverityText:         string(notification.Severity),
+			Body:                 anyValue{StringValue: notification.Message},
+			Attributes:           append(attributes, dimensionAttributes(notification.Dimensions)...),
+		})
+	}
+	return &exportLogsRequest{ResourceLogs: []resourceLogs{{
+		Resource:  resourceOf(info),
+		ScopeLogs: []scopeLogs{{Scope: scopeOf(), LogRecords: records}},
+	}}}
+}
+
+func resourceOf(info *appliance.ApplianceInfo) resource {
+	attributes := []keyValue{attribute(AttributeServiceName, ServiceName)}
+	if info != nil {
+		for _, attr := range []keyValue{
+			attribute(AttributeServiceVersion, info.AgentVersion),
+			attribute(AttributeApplianceId, info.ApplianceId),
+			attribute(AttributeApplianceType, info.Type),
+			attribute(AttributeApplianceVersion, info.Version),
+		} {
+			if len(attr.Value.StringValue) > 0 {
+				attributes = append(attributes, attr)
+			}
+		}
+	}
+	return resource{Attributes: attributes}
+}
+
+func scopeOf() scope {
+	s := scope{Name: scopeName}
+	if len(version.Major) > 0 {
+		s.Version = version.Long()
+	}
+	return s
+}
+
+func dimensionAttributes(dimensions []*appliance.Dimension) []keyValue {
+	attributes := []keyValue{}
+	for _, dimension := range dimensions {
+		if dimension != nil {
+			attributes = append(attributes, attribute(dimension.Name, dimension.Value))
+		}
+	}
+	return attributes
+}
+
+func attribute(key string, value string) keyValue {
+	return keyValue{Key: key, Value: anyValue{StringValue: value}}
+}
+
+// unixNano formats t as the OTLP JSON encoding of fixed64, fallback for the zero time
+func unixNano(t time.Time, fallback time.Time) string {
+	if t.IsZero() {
+		t = fallback
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,275 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+// Package otlp sends insights as OTLP metrics and notifications as OTLP log
+// records to an OpenTelemetry collector using OTLP/HTTP with JSON encoding
+package otlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/logger"
+)
+
+const (
+	MetricsPath = "/v1/metrics"
+	LogsPath    = "/v1/logs"
+
+	DefaultBatchSize  = 500
+	DefaultMaxRetries = 5
+	DefaultBackoff    = 500 * time.Millisecond
+	DefaultMaxBackoff = 30 * time.Second
+
+	scopeName = "github.com/trilogy-group/gfi-agent-sdk/exporter/otlp"
+)
+
+// Exporter posts insights and notifications to Endpoint, the base url of
+// the collector like http://localhost:4318
+type Exporter struct {
+	Endpoint string
+	Client   *http.Client
+	Headers  map[string]string
+	Gzip     bool
+
+	// items per request, larger exports are split
+	BatchSize int
+
+	// retries of requests failing with network errors, 429, 502, 503 or 504, the delay
+	// doubles from Backoff up to MaxBackoff unless the collector sends Retry-After,
+	// which is capped at MaxBackoff too
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// StatusError is returned when the collector rejects a request
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (E *StatusError) Error() string {
+	return fmt.Sprintf("collector responded with status %d: %s", E.StatusCode, E.Body)
+}
+
+func NewExporter(endpoint string) *Exporter {
+	return &Exporter{
+		Endpoint:   strings.TrimRight(endpoint, "/"),
+		Client:     &http.Client{Timeout: 30 * time.Second},
+		Headers:    map[string]string{},
+		Gzip:       true,
+		BatchSize:  DefaultBatchSize,
+		MaxRetries: DefaultMaxRetries,
+		Backoff:    DefaultBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+	}
+}
+
+// Export sends the insights and notifications of a and reports each batch
+// of insights back to it once the collector accepted it
+func (E *Exporter) Export(ctx context.Context, a appliance.Appliance) error {
+	info, err := a.Info()
+	if err != nil {
+		return fmt.Errorf("failed to get appliance info: %w", err)
+	}
+	if err := E.exportInsights(ctx, info, a.Insights(), a.InsightsPublished); err != nil {
+		return err
+	}
+	return E.ExportNotifications(ctx, info, a.Notifications())
+}
+
+// ExportInsights sends insights as gauge data points of a resource built from info
+func (E *Exporter) ExportInsights(ctx context.Context, info *appliance.ApplianceInfo, insights []*appliance.Insight) error {
+	return E.exportInsights(ctx, info, insights, nil)
+}
+
+// exportInsights reports each batch to published once the collector accepted
+// it, insights without metric are never sent and reported with the first one
+func (E *Exporter) exportInsights(ctx context.Context, info *appliance.ApplianceInfo, insights []*appliance.Insight, published func([]*appliance.Insight)) error {
+	valid := []*appliance.Insight{}
+	skipped := []*appliance.Insight{}
+	for _, insight := range insights {
+		if insight == nil {
+			continue
+		}
+		if insight.Metric == nil {
+			skipped = append(skipped, insight)
+			continue
+		}
+		valid = append(valid, insight)
+	}
+	if len(valid) == 0 && len(skipped) > 0 && published != nil {
+		published(skipped)
+	}
+	for start := 0; start < len(valid); start += E.batchSize() {
+		batch := valid[start:minInt(start+E.batchSize(), len(valid))]
+		if err := E.post(ctx, MetricsPath, metricsRequest(info, batch)); err != nil {
+			return err
+		}
+		if published != nil {
+			if start == 0 {
+				batch = append(skipped, batch...)
+			}
+			published(batch)
+		}
+	}
+	return nil
+}
+
+// ExportNotifications sends notifications as log records of a resource built from info
+func (E *Exporter) ExportNotifications(ctx context.Context, info *appliance.ApplianceInfo, notifications []*appliance.Notification) error {
+	valid := []*appliance.Notification{}
+	for _, notification := range notifications {
+		if notification != nil {
+			valid = append(valid, notification)
+		}
+	}
+	for start := 0; start < len(valid); start += E.batchSize() {
+		batch := valid[start:minInt(start+E.batchSize(), len(valid))]
+		if err := E.post(ctx, LogsPath, logsRequest(info, batch)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (E *Exporter) post(ctx context.Context, path string, request interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	if E.Gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+	}
+
+	backoff := E.Backoff
+	for attempt := 0; ; attempt++ {
+		delay, err := E.send(ctx, path, body)
+		if err == nil {
+			return nil
+		}
+		if delay < 0 || attempt >= E.MaxRetries {
+			return err
+		}
+		if delay == 0 {
+			delay = backoff
+			backoff = minDuration(backoff*2, E.MaxBackoff)
+		} else {
+			delay = minDuration(delay, E.MaxBackoff)
+		}
+		logger.Logger.Warningf("Retrying OTLP export to %s in %s: %s", path, delay, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// send posts body once, the returned delay is negative when the request must
+// not be retried and positive when the collector asked for it
+func (E *Exporter) send(ctx context.Context, path string, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, E.Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if E.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for name, value := range E.Headers {
+		req.Header.Set(name, value)
+	}
+
+	client := E.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return -1, ctx.Err()
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return 0, nil
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(message))}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return retryAfter(resp.Header.Get("Retry-After")), err
+	default:
+		return -1, err
+	}
+}
+
+// retryAfter parses the delay in seconds or as an HTTP date, zero when
+// missing, invalid or past
+func retryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0
+	}
+	if delay := time.Until(at); delay > 0 {
+		return delay
+	}
+	return 0
+}
+
+func (E *Exporter) batchSize() int {
+	if E.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+	return E.BatchSize
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if b > 0 && b < a {
+		return b
+	}
+	return a
+}
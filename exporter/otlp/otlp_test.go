@@ -0,0 +1,235 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package otlp_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance/appliancetest"
+	"github.com/trilogy-group/gfi-agent-sdk/exporter/otlp"
+)
+
+// collector records the decoded requests and fails the first failures of them
+type collector struct {
+	mu         sync.Mutex
+	failures   int
+	status     int
+	retryAfter string
+
+	// requests accepted before all others fail with status, zero for no limit
+	limit    int
+	accepted int
+	requests map[string][]map[string]interface{}
+}
+
+func (C *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	C.mu.Lock()
+	defer C.mu.Unlock()
+	if C.failures > 0 || (C.limit > 0 && C.accepted >= C.limit) {
+		if C.failures > 0 {
+			C.failures--
+		}
+		if len(C.retryAfter) > 0 {
+			w.Header().Set("Retry-After", C.retryAfter)
+		}
+		w.WriteHeader(C.status)
+		w.Write([]byte("try later"))
+		return
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	request := map[string]interface{}{}
+	if err := json.NewDecoder(body).Decode(&request); err != nil || r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	C.accepted++
+	C.requests[r.URL.Path] = append(C.requests[r.URL.Path], request)
+	w.Write([]byte("{}"))
+}
+
+func newCollector(t *testing.T) (*collector, *otlp.Exporter) {
+	c := &collector{requests: map[string][]map[string]interface{}{}}
+	server := httptest.NewServer(c)
+	t.Cleanup(server.Close)
+	exporter := otlp.NewExporter(server.URL + "/")
+	exporter.Backoff = time.Millisecond
+	return c, exporter
+}
+
+// path walks decoded JSON by map keys and slice indexes
+func path(value interface{}, keys ...interface{}) interface{} {
+	for _, key := range keys {
+		switch key := key.(type) {
+		case string:
+			value = value.(map[string]interface{})[key]
+		case int:
+			value = value.([]interface{})[key]
+		}
+	}
+	return value
+}
+
+func TestExport(t *testing.T) {
+	assert := assert.New(t)
+	c, exporter := newCollector(t)
+	exporter.BatchSize = 2
+
+	a := appliancetest.NewFakeAppliance(&appliance.Config{Id: "1", Type: "Kerio-Connect"})
+	a.ApplianceInfo = &appliance.ApplianceInfo{ApplianceId: "1", Type: "Kerio-Connect", Version: "10.0.5", AgentVersion: "2.1.0"}
+	timestamp := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, name := range []string{"a", "b", "c"} {
+		a.InsightList = append(a.InsightList, &appliance.Insight{
+			Name:       name,
+			Metric:     &appliance.Metric{Unit: "Milliseconds", Value: 1.5},
+			Dimensions: []*appliance.Dimension{{Name: "queue", Value: "inbound"}},
+			Timestamp:  appliance.JSONTime(timestamp),
+		})
+	}
+	a.NotificationList = []*appliance.Notification{{Type: "license", Name: "LicenseExpiring", Severity: appliance.SeverityWarning, Message: "expires soon"}}
+
+	assert.NoError(exporter.Export(context.Background(), a))
+	// each accepted batch is reported
+	assert.Equal([][]*appliance.Insight{a.InsightList[:2], a.InsightList[2:]}, a.Published())
+
+	metrics := c.requests[otlp.MetricsPath]
+	assert.Len(metrics, 2)
+	resourceMetrics := path(metrics[0], "resourceMetrics", 0)
+	assert.Equal([]interface{}{
+		map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "gfi-agent"}},
+		map[string]interface{}{"key": "service.version", "value": map[string]interface{}{"stringValue": "2.1.0"}},
+		map[string]interface{}{"key": "gfi.appliance.id", "value": map[string]interface{}{"stringValue": "1"}},
+		map[string]interface{}{"key": "gfi.appliance.type", "value": map[string]interface{}{"stringValue": "Kerio-Connect"}},
+		map[string]interface{}{"key": "gfi.appliance.version", "value": map[string]interface{}{"stringValue": "10.0.5"}},
+	}, path(resourceMetrics, "resource", "attributes"))
+	metric := path(resourceMetrics, "scopeMetrics", 0, "metrics", 1)
+	assert.Equal("b", path(metric, "name"))
+	assert.Equal("ms", path(metric, "unit"))
+	assert.Equal(1.5, path(metric, "gauge", "dataPoints", 0, "asDouble"))
+	assert.Equal("1704067200000000000", path(metric, "gauge", "dataPoints", 0, "timeUnixNano"))
+	assert.Equal("queue", path(metric, "gauge", "dataPoints", 0, "attributes", 0, "key"))
+	assert.Len(path(metrics[1], "resourceMetrics", 0, "scopeMetrics", 0, "metrics"), 1)
+
+	logs := c.requests[otlp.LogsPath]
+	assert.Len(logs, 1)
+	record := path(logs[0], "resourceLogs", 0, "scopeLogs", 0, "logRecords", 0)
+	assert.Equal(float64(13), path(record, "severityNumber"))
+	assert.Equal("Warning", path(record, "severityText"))
+	assert.Equal("expires soon", path(record, "body", "stringValue"))
+}
+
+func TestExportRetries(t *testing.T) {
+	assert := assert.New(t)
+	c, exporter := newCollector(t)
+	exporter.Gzip = false
+	insights := []*appliance.Insight{{Name: "a", Metric: &appliance.Metric{Unit: "Count", Value: 1}}}
+
+	c.failures, c.status = 2, http.StatusServiceUnavailable
+	assert.NoError(exporter.ExportInsights(context.Background(), nil, insights))
+	assert.Len(c.requests[otlp.MetricsPath], 1)
+
+	c.failures, c.status = 1, http.StatusBadRequest
+	err := exporter.ExportInsights(context.Background(), nil, insights)
+	var statusErr *otlp.StatusError
+	assert.True(errors.As(err, &statusErr))
+	assert.Equal(http.StatusBadRequest, statusErr.StatusCode)
+	assert.Equal("try later", statusErr.Body)
+
+	exporter.MaxRetries = 1
+	c.failures, c.status = 5, http.StatusTooManyRequests
+	assert.Error(exporter.ExportInsights(context.Background(), nil, insights))
+	assert.Equal(3, c.failures)
+	assert.Len(c.requests[otlp.MetricsPath], 1)
+}
+
+func TestExportReportsAcceptedBatches(t *testing.T) {
+	assert := assert.New(t)
+	c, exporter := newCollector(t)
+	exporter.BatchSize = 1
+	a := appliancetest.NewFakeAppliance(&appliance.Config{Id: "1"})
+	a.ApplianceInfo = &appliance.ApplianceInfo{ApplianceId: "1"}
+	a.InsightList = []*appliance.Insight{
+		{Name: "none"},
+		{Name: "a", Metric: &appliance.Metric{Unit: "Count", Value: 1}},
+		{Name: "b", Metric: &appliance.Metric{Unit: "Count", Value: 2}},
+	}
+
+	// the collector rejects the second batch
+	c.limit, c.status = 1, http.StatusBadRequest
+	assert.Error(exporter.Export(context.Background(), a))
+	assert.Equal([][]*appliance.Insight{a.InsightList[:2]}, a.Published())
+}
+
+func TestRetryAfterDate(t *testing.T) {
+	assert := assert.New(t)
+	c, exporter := newCollector(t)
+	insights := []*appliance.Insight{{Name: "a", Metric: &appliance.Metric{Unit: "Count", Value: 1}}}
+
+	c.failures, c.status = 1, http.StatusServiceUnavailable
+	c.retryAfter = time.Now().Add(2 * time.Second).UTC().Format(http.TimeFormat)
+	start := time.Now()
+	assert.NoError(exporter.ExportInsights(context.Background(), nil, insights))
+	assert.Greater(time.Since(start), 500*time.Millisecond)
+
+	// dates in the past fall back to the backoff
+	c.failures = 1
+	c.retryAfter = time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	start = time.Now()
+	assert.NoError(exporter.ExportInsights(context.Background(), nil, insights))
+	assert.Less(time.Since(start), 500*time.Millisecond)
+
+	// delays sent by the collector are capped at MaxBackoff
+	exporter.MaxBackoff = 50 * time.Millisecond
+	c.failures = 1
+	c.retryAfter = "3600"
+	start = time.Now()
+	assert.NoError(exporter.ExportInsights(context.Background(), nil, insights))
+	assert.Less(time.Since(start), 500*time.Millisecond)
+}
+
+func TestUCUMUnits(t *testing.T) {
+	assert := assert.New(t)
+	c, exporter := newCollector(t)
+	units := map[string]string{
+		"Kilobits":         "kbit",
+		"Terabytes":        "TiBy",
+		"Megabytes/Second": "MiBy/s",
+		"kbps":             "kbit/s",
+		"Count/Second":     "1/s",
+		"furlongs":         "furlongs",
+	}
+	insights := []*appliance.Insight{}
+	for unit := range units {
+		insights = append(insights, &appliance.Insight{Name: unit, Metric: &appliance.Metric{Unit: unit, Value: 1}})
+	}
+	assert.NoError(exporter.ExportInsights(context.Background(), nil, insights))
+
+	for _, metric := range path(c.requests[otlp.MetricsPath][0], "resourceMetrics", 0, "scopeMetrics", 0, "metrics").([]interface{}) {
+		name := path(metric, "name").(string)
+		assert.Equal(units[name], path(metric, "unit"), name)
+	}
+}
@@ -0,0 +1,299 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+// Package prometheus exposes the insights of managed appliances in the
+// Prometheus text exposition format
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/logger"
+)
+
+const (
+	DefaultNamespace = "gfiagent"
+	DefaultMaxSeries = 10000
+	DefaultMaxAge    = 15 * time.Minute
+
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// Source provides the appliances collected on each scrape, like *appliance.Manager
+type Source interface {
+	Snapshot() []appliance.Appliance
+}
+
+// unit suffixes by unit kind, Prometheus prefers base units so values are
+// converted to bytes and seconds
+var unitSuffixes = map[string]string{
+	appliance.UnitKindData:     "bytes",
+	appliance.UnitKindDataRate: "bytes_per_second",
+	appliance.UnitKindTime:     "seconds",
+	appliance.UnitKindRate:     "per_second",
+	appliance.UnitKindPercent:  "percent",
+	appliance.UnitKindCount:    "",
+	appliance.UnitKindNone:     "",
+}
+
+// Exporter is an http.Handler serving the insights of all appliances of
+// Source. Insights are collected on each scrape and the last value of every
+// series is kept, so appliances may report each value only once. Series not
+// reported for MaxAge are dropped and new series beyond MaxSeries are ignored.
+type Exporter struct {
+	Source    Source
+	Namespace string
+
+	// cardinality and staleness limits, zero disables them
+	MaxSeries int
+	MaxAge    time.Duration
+
+	mu      sync.Mutex
+	series  map[string]*series
+	dropped uint64
+}
+
+type series struct {
+	name    string
+	help    string
+	labels  string
+	value   float64
+	updated time.Time
+}
+
+func NewExporter(source Source) *Exporter {
+	return &Exporter{
+		Source:    source,
+		Namespace: DefaultNamespace,
+		MaxSeries: DefaultMaxSeries,
+		MaxAge:    DefaultMaxAge,
+		series:    map[string]*series{},
+	}
+}
+
+func (E *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	if err := E.Write(w); err != nil {
+		logger.Logger.Warningf("Failed to write prometheus metrics: %s", err)
+	}
+}
+
+// Write collects insights from Source and writes all current series
+func (E *Exporter) Write(w io.Writer) error {
+	now := time.Now()
+	collected := []*appliance.Insight{}
+	owners := []appliance.Appliance{}
+	if E.Source != nil {
+		for _, a := range E.Source.Snapshot() {
+			for _, insight := range a.Insights() {
+				collected = append(collected, insight)
+				owners = append(owners, a)
+			}
+		}
+	}
+
+	E.mu.Lock()
+	for i, insight := range collected {
+		E.update(owners[i], insight, now)
+	}
+	E.expire(now)
+	current := make([]*series, 0, len(E.series))
+	for _, s := range E.series {
+		current = append(current, s)
+	}
+	dropped := E.dropped
+	E.mu.Unlock()
+
+	sort.Slice(current, func(i, j int) bool {
+		if current[i].name != current[j].name {
+			return current[i].name < current[j].name
+		}
+		return current[i].labels < current[j].labels
+	})
+
+	bw := bufio.NewWriter(w)
+	previous := ""
+	for _, s := range current {
+		if s.name != previous {
+			fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n", s.name, escapeHelp(s.help), s.name)
+			previous = s.name
+		}
+		fmt.Fprintf(bw, "%s{%s} %s\n", s.name, s.labels, formatValue(s.value))
+	}
+
+	own := E.namespace() + "_exporter_"
+	fmt.Fprintf(bw, "# HELP %sseries Series currently exported.\n# TYPE %sseries gauge\n%sseries %d\n", own, own, own, len(current))
+	fmt.Fprintf(bw, "# HELP %sdropped_series_total Insights dropped by the series limit.\n# TYPE %sdropped_series_total counter\n%sdropped_series_total %d\n", own, own, own, dropped)
+	return bw.Flush()
+}
+
+// update records insight as the last value of its series, it must be called
+// with mu held
+func (E *Exporter) update(a appliance.Appliance, insight *appliance.Insight, now time.Time) {
+	if insight == nil || insight.Metric == nil {
+		return
+	}
+	if E.series == nil {
+		E.series = map[string]*series{}
+	}
+	name, scale := E.MetricName(insight.Name, insight.Metric.Unit)
+	labels := Labels(a, insight.Dimensions)
+	key := name + "{" + labels + "}"
+
+	s, ok := E.series[key]
+	if !ok {
+		if E.MaxSeries > 0 && len(E.series) >= E.MaxSeries {
+			E.dropped++
+			return
+		}
+		s = &series{name: name, labels: labels, help: "Insight " + insight.Name}
+		E.series[key] = s
+	}
+	s.value = insight.Metric.Value * scale
+	s.updated = now
+}
+
+func (E *Exporter) expire(now time.Time) {
+	if E.MaxAge <= 0 {
+		return
+	}
+	for key, s := range E.series {
+		if now.Sub(s.updated) > E.MaxAge {
+			delete(E.series, key)
+		}
+	}
+}
+
+func (E *Exporter) namespace() string {
+	if len(E.Namespace) == 0 {
+		return DefaultNamespace
+	}
+	return sanitize(E.Namespace)
+}
+
+// MetricName returns the Prometheus name of an insight with the unit suffix
+// and the factor converting values to that unit
+func (E *Exporter) MetricName(name string, unit string) (string, float64) {
+	metric := sanitize(E.namespace() + "_" + snakeCase(name))
+	suffix, scale := sanitize(snakeCase(unit)), 1.0
+	if known, ok := appliance.LookupUnit(unit); ok {
+		suffix, scale = unitSuffixes[known.Kind], known.Factor
+		if known.Kind == appliance.UnitKindData || known.Kind == appliance.UnitKindDataRate {
+			// data units are counted in bits
+			scale /= 8
+		}
+	}
+	if len(suffix) > 0 && !strings.HasSuffix(metric, "_"+suffix) {
+		metric += "_" + suffix
+	}
+	return metric, scale
+}
+
+// Labels formats the appliance and dimensions as label pairs, dimensions
+// clashing with the appliance labels or each other after sanitizing are skipped
+func Labels(a appliance.Appliance, dimensions []*appliance.Dimension) string {
+	names := []string{"appliance_id", "appliance_type"}
+	values := map[string]string{"appliance_id": a.Id(), "appliance_type": a.Type()}
+	for _, dimension := range dimensions {
+		if dimension == nil {
+			continue
+		}
+		name := sanitize(snakeCase(dimension.Name))
+		if _, exists := values[name]; exists || len(name) == 0 {
+			continue
+		}
+		names = append(names, name)
+		values[name] = dimension.Value
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"=\""+escapeLabel(values[name])+"\"")
+	}
+	return strings.Join(pairs, ",")
+}
+
+// sanitize replaces characters not allowed in metric and label names, runs
+// of underscores are collapsed so names never start with the reserved __
+func sanitize(name string) string {
+	var sb strings.Builder
+	underscore := false
+	for i, r := range name {
+		valid := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9' && i > 0)
+		if !valid {
+			if i == 0 && r >= '0' && r <= '9' {
+				sb.WriteRune('_')
+				sb.WriteRune(r)
+				underscore = false
+				continue
+			}
+			r = '_'
+		}
+		if r == '_' {
+			if underscore {
+				continue
+			}
+			underscore = true
+		} else {
+			underscore = false
+		}
+		sb.WriteRune(r)
+	}
+	return strings.TrimRight(sb.String(), "_")
+}
+
+// snakeCase converts names like ActiveUsers or activeUsers to active_users
+func snakeCase(name string) string {
+	var sb strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		upper := r >= 'A' && r <= 'Z'
+		if upper && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && runes[i+1] >= 'a' && runes[i+1] <= 'z'
+			if (prev >= 'a' && prev <= 'z') || (prev >= '0' && prev <= '9') || ((prev >= 'A' && prev <= 'Z') && nextLower) {
+				sb.WriteRune('_')
+			}
+		}
+		if upper {
+			r += 'a' - 'A'
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func escapeHelp(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(value)
+}
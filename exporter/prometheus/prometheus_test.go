@@ -0,0 +1,125 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package prometheus_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance/appliancetest"
+	"github.com/trilogy-group/gfi-agent-sdk/exporter/prometheus"
+)
+
+func fake(id string, insights ...*appliance.Insight) *appliancetest.FakeAppliance {
+	a := appliancetest.NewFakeAppliance(&appliance.Config{Id: id, Type: "Kerio-Connect"})
+	a.InsightList = insights
+	return a
+}
+
+func TestExporterHandler(t *testing.T) {
+	assert := assert.New(t)
+	manager := appliance.NewManager(t.TempDir(), appliancetest.Factory)
+	assert.NoError(manager.Add(fake("1",
+		&appliance.Insight{Name: "ActiveUsers", Metric: &appliance.Metric{Unit: "Count", Value: 12}},
+		&appliance.Insight{Name: "queue.latency", Metric: &appliance.Metric{Unit: "Milliseconds", Value: 250},
+			Dimensions: []*appliance.Dimension{{Name: "Queue Name", Value: `in"bound`}, {Name: "appliance_id", Value: "spoofed"}}},
+		&appliance.Insight{Name: "no metric"},
+	)))
+	assert.NoError(manager.Add(fake("2",
+		&appliance.Insight{Name: "ActiveUsers", Metric: &appliance.Metric{Unit: "Count", Value: 3}},
+	)))
+
+	recorder := httptest.NewRecorder()
+	prometheus.NewExporter(manager).ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(prometheus.ContentType, recorder.Header().Get("Content-Type"))
+	assert.Equal(`# HELP gfiagent_active_users Insight ActiveUsers
+# TYPE gfiagent_active_users gauge
+gfiagent_active_users{appliance_id="1",appliance_type="Kerio-Connect"} 12
+gfiagent_active_users{appliance_id="2",appliance_type="Kerio-Connect"} 3
+# HELP gfiagent_queue_latency_seconds Insight queue.latency
+# TYPE gfiagent_queue_latency_seconds gauge
+gfiagent_queue_latency_seconds{appliance_id="1",appliance_type="Kerio-Connect",queue_name="in\"bound"} 0.25
+# HELP gfiagent_exporter_series Series currently exported.
+# TYPE gfiagent_exporter_series gauge
+gfiagent_exporter_series 3
+# HELP gfiagent_exporter_dropped_series_total Insights dropped by the series limit.
+# TYPE gfiagent_exporter_dropped_series_total counter
+gfiagent_exporter_dropped_series_total 0
+`, recorder.Body.String())
+}
+
+type source struct {
+	appliances []appliance.Appliance
+}
+
+func (S *source) Snapshot() []appliance.Appliance {
+	return S.appliances
+}
+
+func TestExporterLimits(t *testing.T) {
+	assert := assert.New(t)
+	a := fake("1",
+		&appliance.Insight{Name: "a", Metric: &appliance.Metric{Unit: "Bytes", Value: 1}},
+		&appliance.Insight{Name: "b", Metric: &appliance.Metric{Unit: "Kilobytes", Value: 1}},
+	)
+	exporter := prometheus.NewExporter(&source{appliances: []appliance.Appliance{a}})
+	exporter.MaxSeries = 1
+	exporter.MaxAge = 50 * time.Millisecond
+
+	recorder := httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(recorder.Body.String(), `gfiagent_a_bytes{appliance_id="1",appliance_type="Kerio-Connect"} 1`)
+	assert.NotContains(recorder.Body.String(), "gfiagent_b_bytes")
+	assert.Contains(recorder.Body.String(), "gfiagent_exporter_dropped_series_total 1\n")
+
+	// the last value is kept until it becomes stale
+	a.InsightList = nil
+	recorder = httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(recorder.Body.String(), "gfiagent_a_bytes{")
+
+	time.Sleep(100 * time.Millisecond)
+	recorder = httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.NotContains(recorder.Body.String(), "gfiagent_a_bytes{")
+	assert.Contains(recorder.Body.String(), "gfiagent_exporter_series 0\n")
+}
+
+func TestMetricName(t *testing.T) {
+	assert := assert.New(t)
+	exporter := prometheus.NewExporter(nil)
+	for input, expected := range map[[2]string]string{
+		{"HTTPRequests", "Count/Second"}: "gfiagent_http_requests_per_second",
+		{"disk.free-space", "Gigabytes"}: "gfiagent_disk_free_space_bytes",
+		{"uptime_seconds", "Seconds"}:    "gfiagent_uptime_seconds",
+		{"latency", "ms"}:                "gfiagent_latency_seconds",
+		{"throughput", "Mbps"}:           "gfiagent_throughput_bytes_per_second",
+		{"3rd party", "Widgets"}:         "gfiagent_3rd_party_widgets",
+	} {
+		name, _ := exporter.MetricName(input[0], input[1])
+		assert.Equal(expected, name)
+	}
+	_, scale := exporter.MetricName("disk", "Gigabytes")
+	assert.Equal(float64(1<<30), scale)
+	_, scale = exporter.MetricName("link", "Kilobits")
+	assert.Equal(float64(125), scale)
+}
+
+func TestExporterLiteral(t *testing.T) {
+	assert := assert.New(t)
+	a := fake("1", &appliance.Insight{Name: "ActiveUsers", Metric: &appliance.Metric{Unit: "Count", Value: 12}})
+	exporter := &prometheus.Exporter{Source: &source{appliances: []appliance.Appliance{a}}}
+
+	recorder := httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(recorder.Body.String(), `gfiagent_active_users{appliance_id="1",appliance_type="Kerio-Connect"} 12`)
+	assert.Contains(recorder.Body.String(), "gfiagent_exporter_series 1\n")
+}
@@ -0,0 +1,316 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+// Package stream packs insights and notifications into size limited records
+// and batches for stream services like Kinesis, and maps per record results
+// back to the items they carry
+package stream
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/logger"
+)
+
+// limits of Kinesis PutRecords
+const (
+	DefaultMaxRecordBytes  = 1024 * 1024
+	DefaultMaxBatchBytes   = 5 * 1024 * 1024
+	DefaultMaxBatchRecords = 500
+)
+
+// partition key of items without one, Kinesis rejects empty keys
+const DefaultPartitionKey = "unknown"
+
+var ErrItemTooLarge = errors.New("item exceeds the record size limit")
+
+// PartitionKeyFunc returns the partition key of item, an *appliance.Insight or
+// an *appliance.Notification
+type PartitionKeyFunc func(item interface{}) string
+
+// ByApplianceId keeps the items of an appliance in one shard, the encoder
+// puts items without appliance id under DefaultPartitionKey
+func ByApplianceId(item interface{}) string {
+	switch item := item.(type) {
+	case *appliance.Insight:
+		return item.ApplianceId
+	case *appliance.Notification:
+		return item.ApplianceId
+	}
+	return ""
+}
+
+// Record is the data of one stream record and the items encoded in it. Data
+// is a JSON object with insights and notifications arrays, gzipped if enabled.
+type Record struct {
+	PartitionKey  string
+	Data          []byte
+	Insights      []*appliance.Insight
+	Notifications []*appliance.Notification
+}
+
+// Size is what the record counts against batch limits
+func (R *Record) Size() int {
+	return len(R.Data) + len(R.PartitionKey)
+}
+
+// Batch is the records of one put request
+type Batch struct {
+	Records []*Record
+}
+
+func (B *Batch) Size() int {
+	size := 0
+	for _, record := range B.Records {
+		size += record.Size()
+	}
+	return size
+}
+
+// Split divides the records by the per record results of putting the batch,
+// results[i] is nil when Records[i] was stored. Records without a result are
+// taken as failed.
+func (B *Batch) Split(results []error) (delivered []*Record, failed []*Record) {
+	for i, record := range B.Records {
+		if i < len(results) && results[i] == nil {
+			delivered = append(delivered, record)
+		} else {
+			failed = append(failed, record)
+		}
+	}
+	return delivered, failed
+}
+
+// Insights returns the insights carried by records, pass the delivered
+// records to Appliance.InsightsPublished
+func Insights(records []*Record) []*appliance.Insight {
+	insights := []*appliance.Insight{}
+	for _, record := range records {
+		insights = append(insights, record.Insights...)
+	}
+	return insights
+}
+
+func Notifications(records []*Record) []*appliance.Notification {
+	notifications := []*appliance.Notification{}
+	for _, record := range records {
+		notifications = append(notifications, record.Notifications...)
+	}
+	return notifications
+}
+
+// Encoder packs items into records and records into batches, zero limits
+// take the Kinesis defaults. Record sizes are checked before compression.
+type Encoder struct {
+	MaxRecordBytes  int
+	MaxBatchBytes   int
+	MaxBatchRecords int
+
+	// items per record, zero packs as many as fit
+	MaxRecordItems int
+
+	Gzip         bool
+	PartitionKey PartitionKeyFunc
+
+	// format of the item timestamps, NewEncoder takes appliance.GetTimeFormat
+	TimeFormat appliance.TimeFormat
+}
+
+func NewEncoder() *Encoder {
+	return &Encoder{
+		MaxRecordBytes:  DefaultMaxRecordBytes,
+		MaxBatchBytes:   DefaultMaxBatchBytes,
+		MaxBatchRecords: DefaultMaxBatchRecords,
+		PartitionKey:    ByApplianceId,
+		TimeFormat:      appliance.GetTimeFormat(),
+	}
+}
+
+// payload is the JSON content of a record
+type payload struct {
+	Insights      []json.RawMessage `json:"insights,omitempty"`
+	Notifications []json.RawMessage `json:"notifications,omitempty"`
+}
+
+// size of {"insights":[],"notifications":[]}
+const payloadOverhead = len(`{"insights":[],"notifications":[]}`)
+
+// pending collects the items of the record being packed for a partition key
+type pending struct {
+	record        *Record
+	insights      []json.RawMessage
+	notifications []json.RawMessage
+
+	// bytes counted against the record limit, including the partition key
+	size int
+}
+
+func (P *pending) items() int {
+	return len(P.insights) + len(P.notifications)
+}
+
+// Encode packs insights and notifications into batches. Items which do not
+// fit into a record on their own are skipped and returned in the error.
+func (E *Encoder) Encode(insights []*appliance.Insight, notifications []*appliance.Notification) ([]*Batch, error) {
+	records, err := E.EncodeRecords(insights, notifications)
+	return E.Batch(records), err
+}
+
+// EncodeRecords packs items into records, keeping the order of the items
+// within each partition key
+func (E *Encoder) EncodeRecords(insights []*appliance.Insight, notifications []*appliance.Notification) ([]*Record, error) {
+	open := map[string]*pending{}
+	records := []*Record{}
+	errs := []error{}
+
+	add := func(item interface{}) error {
+		data, err := appliance.MarshalJSONWithTimeFormat(item, E.TimeFormat)
+		if err != nil {
+			return err
+		}
+		// the partition key counts against the record size limit
+		key := E.partitionKey(item)
+		if payloadOverhead+len(key)+len(data) > E.maxRecordBytes() {
+			return fmt.Errorf("%w: %s", ErrItemTooLarge, item)
+		}
+
+		p, ok := open[key]
+		if ok && (p.size+len(data)+1 > E.maxRecordBytes() || (E.MaxRecordItems > 0 && p.items() >= E.MaxRecordItems)) {
+			if err := E.seal(p); err != nil {
+				return err
+			}
+			ok = false
+		}
+		if !ok {
+			p = &pending{record: &Record{PartitionKey: key}, size: len(key) + payloadOverhead - 1}
+			open[key] = p
+			records = append(records, p.record)
+		}
+
+		// each item after the first of an array adds a comma
+		p.size += len(data) + 1
+		switch item := item.(type) {
+		case *appliance.Insight:
+			p.insights = append(p.insights, data)
+			p.record.Insights = append(p.record.Insights, item)
+		case *appliance.Notification:
+			p.notifications = append(p.notifications, data)
+			p.record.Notifications = append(p.record.Notifications, item)
+		}
+		return nil
+	}
+
+	for _, insight := range insights {
+		if insight != nil {
+			if err := add(insight); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for _, notification := range notifications {
+		if notification != nil {
+			if err := add(notification); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(open))
+	for key := range open {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := E.seal(open[key]); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(errs) > 0 {
+		logger.Logger.Warningf("Skipped %d items which could not be encoded", len(errs))
+	}
+	return records, errors.Join(errs...)
+}
+
+// seal encodes the collected items into the data of the record
+func (E *Encoder) seal(p *pending) error {
+	data, err := json.Marshal(&payload{Insights: p.insights, Notifications: p.notifications})
+	if err != nil {
+		return err
+	}
+	if E.Gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	p.record.Data = data
+	return nil
+}
+
+// Batch groups records into batches within the batch limits, use it to
+// retry the failed records of earlier batches
+func (E *Encoder) Batch(records []*Record) []*Batch {
+	batches := []*Batch{}
+	var current *Batch
+	size := 0
+	for _, record := range records {
+		if current == nil || len(current.Records) >= E.maxBatchRecords() || size+record.Size() > E.maxBatchBytes() {
+			current = &Batch{}
+			batches = append(batches, current)
+			size = 0
+		}
+		current.Records = append(current.Records, record)
+		size += record.Size()
+	}
+	return batches
+}
+
+func (E *Encoder) partitionKey(item interface{}) string {
+	key := ""
+	if E.PartitionKey == nil {
+		key = ByApplianceId(item)
+	} else {
+		key = E.PartitionKey(item)
+	}
+	if len(key) == 0 {
+		return DefaultPartitionKey
+	}
+	return key
+}
+
+func (E *Encoder) maxRecordBytes() int {
+	if E.MaxRecordBytes <= 0 {
+		return DefaultMaxRecordBytes
+	}
+	return E.MaxRecordBytes
+}
+
+func (E *Encoder) maxBatchBytes() int {
+	if E.MaxBatchBytes <= 0 {
+		return DefaultMaxBatchBytes
+	}
+	return E.MaxBatchBytes
+}
+
+func (E *Encoder) maxBatchRecords() int {
+	if E.MaxBatchRecords <= 0 {
+		return DefaultMaxBatchRecords
+	}
+	return E.MaxBatchRecords
+}
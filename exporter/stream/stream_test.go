@@ -0,0 +1,136 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package stream_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trilogy-group/gfi-agent-sdk/appliance"
+	"github.com/trilogy-group/gfi-agent-sdk/exporter/stream"
+)
+
+func insights(applianceId string, names ...string) []*appliance.Insight {
+	result := []*appliance.Insight{}
+	for _, name := range names {
+		result = append(result, &appliance.Insight{Name: name, ApplianceId: applianceId, Metric: &appliance.Metric{Unit: "Count", Value: 1}})
+	}
+	return result
+}
+
+type decoded struct {
+	Insights      []*appliance.Insight      `json:"insights"`
+	Notifications []*appliance.Notification `json:"notifications"`
+}
+
+func TestEncodeRecordsAndBatches(t *testing.T) {
+	assert := assert.New(t)
+	encoder := stream.NewEncoder()
+	encoder.MaxRecordItems = 2
+	encoder.MaxBatchRecords = 2
+
+	items := append(insights("1", "a", "b", "c"), insights("2", "d")...)
+	notifications := []*appliance.Notification{{Name: "n", ApplianceId: "2", Severity: appliance.SeverityInfo}}
+	batches, err := encoder.Encode(items, notifications)
+	assert.NoError(err)
+	assert.Len(batches, 2)
+	assert.Len(batches[0].Records, 2)
+
+	records := append(batches[0].Records, batches[1].Records...)
+	keys := []string{}
+	for _, record := range records {
+		keys = append(keys, record.PartitionKey)
+	}
+	assert.Equal([]string{"1", "1", "2"}, keys)
+	assert.Equal(items[:2], records[0].Insights)
+	assert.Equal(notifications, records[2].Notifications)
+
+	content := &decoded{}
+	assert.NoError(json.Unmarshal(records[2].Data, content))
+	assert.Equal("d", content.Insights[0].Name)
+	assert.Equal("n", content.Notifications[0].Name)
+
+	// only the items of failed records are retried
+	delivered, failed := batches[0].Split([]error{errors.New("ProvisionedThroughputExceededException"), nil})
+	assert.Equal(items[2:3], stream.Insights(delivered))
+	assert.Equal(items[:2], stream.Insights(failed))
+	retry := encoder.Batch(append(failed, batches[1].Records...))
+	assert.Len(retry, 1)
+	assert.Same(records[0], retry[0].Records[0])
+}
+
+func TestEncodeSizeLimits(t *testing.T) {
+	assert := assert.New(t)
+	encoder := stream.NewEncoder()
+	encoder.Gzip = true
+	encoder.MaxRecordBytes = 400
+	encoder.MaxBatchBytes = 600
+	encoder.PartitionKey = func(item interface{}) string { return "all" }
+
+	items := insights("1", "a", "b", "c", "d", "e", strings.Repeat("x", 500))
+	batches, err := encoder.Encode(items, nil)
+	assert.ErrorIs(err, stream.ErrItemTooLarge)
+
+	total := 0
+	for _, batch := range batches {
+		assert.LessOrEqual(batch.Size(), 600)
+		for _, record := range batch.Records {
+			zr, err := gzip.NewReader(bytes.NewReader(record.Data))
+			assert.NoError(err)
+			data, err := io.ReadAll(zr)
+			assert.NoError(err)
+			assert.LessOrEqual(len(data), 400)
+
+			content := &decoded{}
+			assert.NoError(json.Unmarshal(data, content))
+			assert.Len(content.Insights, len(record.Insights))
+			total += len(record.Insights)
+		}
+	}
+	assert.Equal(5, total)
+	assert.Greater(len(batches[0].Records)+len(batches[len(batches)-1].Records), 1)
+}
+
+func TestEncodeTimeFormat(t *testing.T) {
+	assert := assert.New(t)
+	encoder := stream.NewEncoder()
+	encoder.TimeFormat = appliance.TimeFormatEpochMillis
+	items := insights("1", "a")
+	items[0].Timestamp = appliance.JSONTime(time.Date(2024, 3, 4, 5, 6, 7, 890000000, time.UTC))
+
+	records, err := encoder.EncodeRecords(items, nil)
+	assert.NoError(err)
+	assert.Contains(string(records[0].Data), `"timestamp":1709528767890`)
+}
+
+func TestPartitionKeyFallbackAndSize(t *testing.T) {
+	assert := assert.New(t)
+	encoder := stream.NewEncoder()
+	records, err := encoder.EncodeRecords(insights("", "a"), []*appliance.Notification{{Name: "n"}})
+	assert.NoError(err)
+	assert.Len(records, 1)
+	assert.Equal(stream.DefaultPartitionKey, records[0].PartitionKey)
+
+	// an item filling the record on its own leaves no room for a long key
+	data, err := json.Marshal(insights("1", "a")[0])
+	assert.NoError(err)
+	encoder.MaxRecordBytes = len(`{"insights":[],"notifications":[]}`) + len(data) + 1
+	encoder.PartitionKey = func(item interface{}) string { return "k" }
+	_, err = encoder.EncodeRecords(insights("1", "a"), nil)
+	assert.NoError(err)
+	encoder.PartitionKey = func(item interface{}) string { return "kk" }
+	_, err = encoder.EncodeRecords(insights("1", "a"), nil)
+	assert.ErrorIs(err, stream.ErrItemTooLarge)
+}
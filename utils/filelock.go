@@ -0,0 +1,89 @@
+/**
+ * This software and associated documentation files (the “Software”),
+ * including GFI AppManager, is the property of GFI USA, LLC and its affiliates.
+ * No part of the Software may be copied, modified, distributed, sold, or otherwise
+ * used except as expressly permitted by the terms of the software license agreement.
+ */
+
+package utils
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+const lockRetryInterval = 50 * time.Millisecond
+
+var (
+	ErrFileLocked = errors.New("file is locked")
+
+	// returned by the platform tryLockFile when the lock is held elsewhere
+	errLockBusy = errors.New("lock is busy")
+)
+
+// FileLock is an advisory lock shared between processes. It is held on a
+// dedicated lock file, so the protected file can be replaced by rename.
+type FileLock struct {
+	file *os.File
+}
+
+// LockFile locks path, creating it when missing. Exclusive locks exclude
+// every other lock, shared locks only exclusive ones. ErrFileLocked is
+// returned when the lock cannot be acquired within timeout.
+//
+// Shared locks do not fail when path is missing and cannot be created, like
+// in a read-only dir. The returned lock is not held then, as no writer could
+// have created the lock file either.
+func LockFile(path string, exclusive bool, timeout time.Duration) (*FileLock, error) {
+	file, err := openLockFile(path, exclusive)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return &FileLock{}, nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err = tryLockFile(file, exclusive)
+		if err == nil {
+			return &FileLock{file: file}, nil
+		}
+		if !errors.Is(err, errLockBusy) {
+			file.Close()
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, ErrFileLocked
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// openLockFile opens path for locking, nil when a shared lock goes without it
+func openLockFile(path string, exclusive bool) (*os.File, error) {
+	if !exclusive {
+		file, err := os.Open(path)
+		if !errors.Is(err, os.ErrNotExist) {
+			return file, err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil && !exclusive {
+		return nil, nil
+	}
+	return file, err
+}
+
+func (F *FileLock) Unlock() error {
+	if F.file == nil {
+		return nil
+	}
+	unlockErr := unlockFile(F.file)
+	if err := F.file.Close(); err != nil {
+		return err
+	}
+	return unlockErr
+}
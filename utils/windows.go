@@ -13,9 +13,11 @@ package utils
 import (
 	"fmt"
 	"net"
+	"os"
 	"strconv"
 	"syscall"
 
+	"golang.org/x/sys/windows"
 	"golang.org/x/sys/windows/registry"
 )
 
@@ -62,9 +64,25 @@ func GetDefaultInterface() (*net.Interface, error) {
 }
 
 func GetDetachedStartAttributes() *syscall.SysProcAttr {
-	return &syscall.SysProcAttr{
+	return &syscall.SysProcAttr{}
+}
+
+func tryLockFile(file *os.File, exclusive bool) error {
+	var flags uint32 = windows.LOCKFILE_FAIL_IMMEDIATELY
+	if exclusive {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	err := windows.LockFileEx(windows.Handle(file.Fd()), flags, 0, 1, 0, &windows.Overlapped{})
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return errLockBusy
 	}
+	return err
 }
+
+func unlockFile(file *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, 1, 0, &windows.Overlapped{})
+}
+
 func init() {
 	GetRegStringValue = GetWindowsRegStringValue
 	SetRegStringValue = SetWindowsRegStringValue